@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Length limits from RFC 5321 section 4.5.3.1
+const (
+	maxLocalPartLength = 64
+	maxDomainLength    = 253
+	maxLabelLength     = 63
+	maxAddressLength   = 254
+)
+
+// normalizeEmail parses an addr-spec as described in RFC 5322, with the
+// UTF-8 extensions from RFC 6531, and returns it lowercased with the domain
+// in its ASCII (punycode) form, so that bücher.de and xn--bcher-kva.de are
+// stored and matched as the same domain.
+//
+// Display names, comments and domain literals like user@[10.0.0.1] aren't
+// accepted; nobody verifies a work address that way.
+func normalizeEmail(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if !utf8.ValidString(address) {
+		return "", errors.New("address is not valid UTF-8")
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "", errors.New("address has no @")
+	}
+	local, domain := address[:at], address[at+1:]
+
+	if err := validateLocalPart(local); err != nil {
+		return "", err
+	}
+
+	asciiDomain, err := toASCIIDomain(domain)
+	if err != nil {
+		return "", err
+	}
+	labels := strings.Split(asciiDomain, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("domain %q has no top-level domain", domain)
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("domain %q has a numeric top-level domain", domain)
+	}
+
+	normalized := strings.ToLower(local) + "@" + asciiDomain
+	if len(normalized) > maxAddressLength {
+		return "", fmt.Errorf("address is longer than %d characters", maxAddressLength)
+	}
+	return normalized, nil
+}
+
+func isValidEmail(email string) bool {
+	_, err := normalizeEmail(email)
+	return err == nil
+}
+
+// validateLocalPart accepts a dot-atom (alice.smith) or a quoted string ("alice smith")
+func validateLocalPart(local string) error {
+	if local == "" {
+		return errors.New("address has nothing before the @")
+	}
+	if len(local) > maxLocalPartLength {
+		return fmt.Errorf("the part before the @ is longer than %d characters", maxLocalPartLength)
+	}
+
+	if strings.HasPrefix(local, `"`) {
+		return validateQuotedString(local)
+	}
+
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return errors.New("the part before the @ has a misplaced dot")
+		}
+		for _, r := range atom {
+			if !isAtext(r) {
+				return fmt.Errorf("the part before the @ contains %q", r)
+			}
+		}
+	}
+	return nil
+}
+
+func validateQuotedString(local string) error {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return errors.New("the part before the @ has an unterminated quote")
+	}
+
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case escaped:
+			// quoted-pair: a backslash followed by any visible character or whitespace
+			if r != ' ' && r != '\t' && !isVisible(r) {
+				return fmt.Errorf("the part before the @ contains %q", r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return errors.New("the part before the @ has an unescaped quote")
+		case r != ' ' && r != '\t' && !isVisible(r):
+			return fmt.Errorf("the part before the @ contains %q", r)
+		}
+	}
+	if escaped {
+		return errors.New("the part before the @ ends with a backslash")
+	}
+	return nil
+}
+
+// isAtext reports whether r may appear unquoted in a local part (RFC 5322 atext, RFC 6531 UTF8-non-ascii)
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r):
+		return true
+	}
+	return r >= utf8.RuneSelf && isVisible(r)
+}
+
+// isVisible reports whether r is a printable, non-space character
+func isVisible(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r > ' ' && r < 0x7f
+	}
+	return r != utf8.RuneError && !(r >= 0x80 && r < 0xa0)
+}
+
+// toASCIIDomain converts a domain to its lowercase ASCII form, turning
+// internationalized labels into punycode, and checks the DNS length limits
+func toASCIIDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return "", errors.New("address has no domain")
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("domain %q is not valid: %v", domain, err)
+	}
+	if len(ascii) > maxDomainLength {
+		return "", fmt.Errorf("domain %q is longer than %d characters", domain, maxDomainLength)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" {
+			return "", fmt.Errorf("domain %q has an empty label", domain)
+		}
+		if len(label) > maxLabelLength {
+			return "", fmt.Errorf("domain %q has a label longer than %d characters", domain, maxLabelLength)
+		}
+	}
+	return ascii, nil
+}
+
+// MXResolver looks up the DNS records that say where a domain's mail goes.
+// *net.Resolver satisfies it; tests can substitute a stub.
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// errNoMailServer means DNS says a domain can't receive email
+var errNoMailServer = errors.New("domain does not accept email")
+
+// mxLookupTimeout bounds the DNS lookups made while a user waits for a reply
+const mxLookupTimeout = 5 * time.Second
+
+// checkMailDomain returns errNoMailServer if the domain has no mail server.
+// Following RFC 5321 section 5.1, a domain without MX records can still
+// receive mail at its A or AAAA address, and a single "." MX record
+// (RFC 7505 null MX) means it accepts no mail at all. Any other error is a
+// failed lookup, not an answer, and is returned as is.
+func checkMailDomain(ctx context.Context, resolver MXResolver, domain string) error {
+	records, err := resolver.LookupMX(ctx, domain)
+	if err == nil && len(records) > 0 {
+		if len(records) == 1 && records[0].Host == "." {
+			return errNoMailServer
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if _, err := resolver.LookupHost(ctx, domain); err != nil {
+		if isNotFound(err) {
+			return errNoMailServer
+		}
+		return err
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// domainAcceptsMail checks an address's domain for a mail server when
+// verification.check_mx is on. If DNS can't give an answer the address is
+// let through, so a resolver outage doesn't stop everyone verifying.
+func (b *Bot) domainAcceptsMail(email string) bool {
+	if !b.config().Verification.CheckMX {
+		return true
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	ctx, cancel := context.WithTimeout(context.Background(), mxLookupTimeout)
+	defer cancel()
+
+	err := checkMailDomain(ctx, b.resolver, domain)
+	if errors.Is(err, errNoMailServer) {
+		return false
+	}
+	if err != nil {
+		LogWarn("MX lookup for %s failed, allowing the address: %v", domain, err)
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string // Empty when the address should be rejected
+	}{
+		{"plain", "Alice@Company.com", "alice@company.com"},
+		{"surrounding space", "  alice@company.com ", "alice@company.com"},
+		{"dotted local part", "alice.smith@company.com", "alice.smith@company.com"},
+		{"consecutive dots in local part", "alice..smith@company.com", ""},
+		{"leading dot in local part", ".alice@company.com", ""},
+		{"trailing dot in local part", "alice.@company.com", ""},
+		{"consecutive dots in domain", "alice@company..com", ""},
+		{"leading dot in domain", "alice@.company.com", ""},
+		{"trailing-dot domain", "alice@company.com.", "alice@company.com"},
+		{"only a dot for a domain", "alice@.", ""},
+		{"IDN domain", "josé@bücher.de", "josé@xn--bcher-kva.de"},
+		{"IDN domain already in punycode", "jose@XN--BCHER-KVA.DE", "jose@xn--bcher-kva.de"},
+		{"quoted local part", `"alice smith"@company.com`, `"alice smith"@company.com`},
+		{"quoted local part with escaped quote", `"alice\"smith"@company.com`, `"alice\"smith"@company.com`},
+		{"quoted local part with @", `"alice@home"@company.com`, `"alice@home"@company.com`},
+		{"unterminated quote", `"alice@company.com`, ""},
+		{"unescaped quote", `"ali"ce"@company.com`, ""},
+		{"quoted local part ending in backslash", `"alice\"@company.com`, ""},
+		{"label of 63 characters", "alice@" + strings.Repeat("a", 63) + ".com", "alice@" + strings.Repeat("a", 63) + ".com"},
+		{"label of 64 characters", "alice@" + strings.Repeat("a", 64) + ".com", ""},
+		{"overlong local part", strings.Repeat("a", 65) + "@company.com", ""},
+		{"overlong domain", "alice@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com", ""},
+		{"no @", "alice.company.com", ""},
+		{"nothing before @", "@company.com", ""},
+		{"no domain", "alice@", ""},
+		{"no top-level domain", "alice@localhost", ""},
+		{"numeric top-level domain", "alice@10.0.0.1", ""},
+		{"space in local part", "alice smith@company.com", ""},
+		{"invalid UTF-8", "alice\xff@company.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeEmail(tt.address)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("normalizeEmail(%q) = %q, want an error", tt.address, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeEmail(%q) returned error: %v", tt.address, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeEmail(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+// stubResolver answers MX and host lookups from fixed results
+type stubResolver struct {
+	mx      []*net.MX
+	mxErr   error
+	hosts   []string
+	hostErr error
+}
+
+func (r stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return r.mx, r.mxErr
+}
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.hosts, r.hostErr
+}
+
+func TestCheckMailDomain(t *testing.T) {
+	notFound := &net.DNSError{Err: "no such host", Name: "company.com", IsNotFound: true}
+	temporary := &net.DNSError{Err: "server misbehaving", Name: "company.com", IsTemporary: true}
+
+	tests := []struct {
+		name     string
+		resolver stubResolver
+		want     error
+	}{
+		{
+			name:     "MX records",
+			resolver: stubResolver{mx: []*net.MX{{Host: "mx1.company.com.", Pref: 10}}},
+		},
+		{
+			name:     "no MX but an address record",
+			resolver: stubResolver{mxErr: notFound, hosts: []string{"192.0.2.1"}},
+		},
+		{
+			name:     "no MX and no address record",
+			resolver: stubResolver{mxErr: notFound, hostErr: notFound},
+			want:     errNoMailServer,
+		},
+		{
+			name:     "null MX",
+			resolver: stubResolver{mx: []*net.MX{{Host: ".", Pref: 0}}},
+			want:     errNoMailServer,
+		},
+		{
+			name:     "temporary error on MX lookup",
+			resolver: stubResolver{mxErr: temporary},
+			want:     temporary,
+		},
+		{
+			name:     "temporary error on host lookup",
+			resolver: stubResolver{mxErr: notFound, hostErr: temporary},
+			want:     temporary,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMailDomain(context.Background(), tt.resolver, "company.com")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkMailDomain() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
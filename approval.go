@@ -0,0 +1,414 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ApprovalStatus tracks a verification through the moderator approval queue
+type ApprovalStatus string
+
+const (
+	ApprovalPending   ApprovalStatus = "pending"   // Waiting for a moderator
+	ApprovalApproved  ApprovalStatus = "approved"  // Approved and roles granted
+	ApprovalDenied    ApprovalStatus = "denied"    // Turned down; the user has to start over
+	ApprovalCancelled ApprovalStatus = "cancelled" // Withdrawn before anyone decided, e.g. the message couldn't be posted
+)
+
+// approvalCustomIDPrefix starts the custom ID of the Approve/Deny buttons;
+// the rest is "approve:<id>" or "deny:<id>"
+const approvalCustomIDPrefix = "heimdall-approval:"
+
+// VerificationApproval records a verified email waiting for, or decided by, a moderator
+type VerificationApproval struct {
+	ID              int64
+	DiscordID       string
+	DiscordUsername string
+	Email           string
+	Status          ApprovalStatus
+	ChannelID       string
+	MessageID       string
+	DecidedByID     string
+	DecidedByName   string
+	CreatedAt       time.Time
+	DecidedAt       *time.Time
+}
+
+const approvalColumns = `id, discord_id, discord_username, email, status,
+		COALESCE(channel_id, ''), COALESCE(message_id, ''), COALESCE(decided_by_id, ''), COALESCE(decided_by_name, ''),
+		created_at, decided_at`
+
+func scanVerificationApproval(row rowScanner) (*VerificationApproval, error) {
+	var approval VerificationApproval
+	var status string
+	var decidedAt sql.NullTime
+	err := row.Scan(&approval.ID, &approval.DiscordID, &approval.DiscordUsername, &approval.Email, &status,
+		&approval.ChannelID, &approval.MessageID, &approval.DecidedByID, &approval.DecidedByName, &approval.CreatedAt, &decidedAt)
+	if err != nil {
+		return nil, err
+	}
+	approval.Status = ApprovalStatus(status)
+	if decidedAt.Valid {
+		approval.DecidedAt = &decidedAt.Time
+	}
+	return &approval, nil
+}
+
+// SubmitForApproval saves a pending user's teams, moves them to awaiting
+// approval and opens an approval record, returning its ID. It returns
+// sql.ErrNoRows if the user is no longer pending.
+func (d *Database) SubmitForApproval(user *User, teams []string) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET awaiting_approval = TRUE WHERE discord_id = ? AND `+pendingCondition, user.DiscordID)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	if err := replaceUserTeams(tx, user.DiscordID, teams); err != nil {
+		return 0, err
+	}
+
+	result, err = tx.Exec(`
+		INSERT INTO verification_approvals (discord_id, discord_username, email, status)
+		VALUES (?, ?, ?, ?)
+	`, user.DiscordID, user.DiscordUsername, user.Email, string(ApprovalPending))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// WithdrawFromApproval cancels an approval and puts the user back to pending,
+// so they can submit the verification page again
+func (d *Database) WithdrawFromApproval(id int64, discordID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET awaiting_approval = FALSE WHERE discord_id = ?`, discordID); err != nil {
+		return err
+	}
+	query := `UPDATE verification_approvals SET status = ?, decided_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`
+	if _, err := tx.Exec(query, string(ApprovalCancelled), id, string(ApprovalPending)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) GetVerificationApproval(id int64) (*VerificationApproval, error) {
+	query := `SELECT ` + approvalColumns + ` FROM verification_approvals WHERE id = ?`
+	return scanVerificationApproval(d.db.QueryRow(query, id))
+}
+
+// SetVerificationApprovalMessage records where an approval message was posted
+func (d *Database) SetVerificationApprovalMessage(id int64, channelID, messageID string) error {
+	_, err := d.db.Exec(`UPDATE verification_approvals SET channel_id = ?, message_id = ? WHERE id = ?`, channelID, messageID, id)
+	return err
+}
+
+// DecideVerificationApproval records a moderator's decision on a pending
+// approval. It returns false if someone else already decided.
+func (d *Database) DecideVerificationApproval(id int64, status ApprovalStatus, decidedByID, decidedByName string) (bool, error) {
+	query := `
+		UPDATE verification_approvals
+		SET status = ?, decided_by_id = ?, decided_by_name = ?, decided_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`
+	result, err := d.db.Exec(query, string(status), decidedByID, decidedByName, id, string(ApprovalPending))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ApproveVerification records a moderator's approval and marks the user
+// verified in one transaction, so a failure leaves the approval pending. It
+// returns false if someone else already decided, and sql.ErrNoRows if the
+// user is no longer awaiting approval.
+func (d *Database) ApproveVerification(approval *VerificationApproval, decidedByID, decidedByName string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE verification_approvals
+		SET status = ?, decided_by_id = ?, decided_by_name = ?, decided_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`
+	result, err := tx.Exec(query, string(ApprovalApproved), decidedByID, decidedByName, approval.ID, string(ApprovalPending))
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	query = `
+		UPDATE users
+		SET verified = TRUE, verified_at = CURRENT_TIMESTAMP, awaiting_approval = FALSE, reverify_requested_at = NULL, reverify_lapsed = FALSE
+		WHERE discord_id = ? AND COALESCE(awaiting_approval, 0) = 1
+	`
+	result, err = tx.Exec(query, approval.DiscordID)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if affected == 0 {
+		return false, sql.ErrNoRows
+	}
+	return true, tx.Commit()
+}
+
+// DeleteAwaitingUser removes a denied user so they can start over, keeping
+// the approval record of who denied them
+func (d *Database) DeleteAwaitingUser(discordID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_teams WHERE discord_id = ?`, discordID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE discord_id = ? AND COALESCE(awaiting_approval, 0) = 1`, discordID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// verificationCompleteDM is sent once a user has their roles
+func (b *Bot) verificationCompleteDM(teams []string) string {
+	if b.config().Features.EnableTeamSelection {
+		return fmt.Sprintf("✅ Verification complete! Welcome to %s. You now have access to the server.", teamPhrase(teams))
+	}
+	return "✅ Verification complete! You now have access to the server."
+}
+
+// requestVerificationApproval holds a user who has just clicked their link
+// and posts them to the approval channel. If the message can't be posted the
+// user is put back to pending and an error is returned.
+func (b *Bot) requestVerificationApproval(user *User, teams []string) error {
+	id, err := b.db.SubmitForApproval(user, teams)
+	if err != nil {
+		return fmt.Errorf("failed to queue for approval: %w", err)
+	}
+
+	approval := &VerificationApproval{
+		ID:              id,
+		DiscordID:       user.DiscordID,
+		DiscordUsername: user.DiscordUsername,
+		Email:           user.Email,
+		Status:          ApprovalPending,
+		CreatedAt:       time.Now(),
+	}
+
+	channelID := b.config().Discord.ApprovalChannelID
+	message, err := b.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{b.approvalEmbed(approval, teams, "⏳ Waiting for a moderator")},
+		Components: approvalButtons(id),
+	})
+	if err != nil {
+		if withdrawErr := b.db.WithdrawFromApproval(id, user.DiscordID); withdrawErr != nil {
+			LogError("Error withdrawing approval %d for %s: %v", id, user.DiscordUsername, withdrawErr)
+		}
+		return fmt.Errorf("failed to post to approval channel: %w", err)
+	}
+
+	if err := b.db.SetVerificationApprovalMessage(id, channelID, message.ID); err != nil {
+		LogError("Error saving message for approval %d: %v", id, err)
+	}
+	return nil
+}
+
+func (b *Bot) approvalEmbed(approval *VerificationApproval, teams []string, status string) *discordgo.MessageEmbed {
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "User", Value: fmt.Sprintf("<@%s>\n%s", approval.DiscordID, approval.DiscordUsername), Inline: true},
+		{Name: "Email", Value: approval.Email, Inline: true},
+	}
+	if b.config().Features.EnableTeamSelection {
+		value := strings.Join(teams, ", ")
+		if value == "" {
+			value = "None"
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Teams", Value: value, Inline: true})
+	}
+	fields = append(fields, &discordgo.MessageEmbedField{Name: "Status", Value: status})
+
+	return &discordgo.MessageEmbed{
+		Title:     "🛂 Verification Awaiting Approval",
+		Color:     0xf1c40f,
+		Fields:    fields,
+		Timestamp: approval.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func approvalButtons(id int64) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: fmt.Sprintf("%sapprove:%d", approvalCustomIDPrefix, id),
+				},
+				discordgo.Button{
+					Label:    "Deny",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%sdeny:%d", approvalCustomIDPrefix, id),
+				},
+			},
+		},
+	}
+}
+
+// handleApprovalButton handles Approve and Deny on a verification awaiting approval
+func (b *Bot) handleApprovalButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to do this.")
+		return
+	}
+
+	decision, idText, _ := strings.Cut(strings.TrimPrefix(i.MessageComponentData().CustomID, approvalCustomIDPrefix), ":")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		LogWarn("Invalid approval button: %s", i.MessageComponentData().CustomID)
+		b.respondEphemeral(s, i, "❌ This request is out of date.")
+		return
+	}
+
+	approval, err := b.db.GetVerificationApproval(id)
+	if err == sql.ErrNoRows {
+		b.respondEphemeral(s, i, "❌ This request no longer exists. The user may have been reset or purged.")
+		return
+	}
+	if err != nil {
+		LogError("Error getting approval %d: %v", id, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+	if approval.Status != ApprovalPending {
+		b.respondEphemeral(s, i, fmt.Sprintf("ℹ️ This request was already %s by %s.", approval.Status, approval.DecidedByName))
+		return
+	}
+
+	user, err := b.db.GetUserByDiscordID(approval.DiscordID)
+	if err != nil || !user.AwaitingApproval {
+		// A moderator verified, reset or restricted them some other way in the meantime
+		if _, err := b.db.DecideVerificationApproval(id, ApprovalCancelled, i.Member.User.ID, i.Member.User.Username); err != nil {
+			LogError("Error cancelling approval %d: %v", id, err)
+		}
+		b.updateApprovalMessage(s, i, approval, nil, "⚠️ Cancelled: the user is no longer awaiting approval")
+		return
+	}
+
+	switch decision {
+	case "approve":
+		b.approveVerification(s, i, approval, user)
+	case "deny":
+		b.denyVerification(s, i, approval, user)
+	}
+}
+
+func (b *Bot) approveVerification(s *discordgo.Session, i *discordgo.InteractionCreate, approval *VerificationApproval, user *User) {
+	moderator := i.Member.User
+
+	// Nothing is saved unless both the approval and the verification are, so
+	// the buttons stay usable after a failure
+	claimed, err := b.db.ApproveVerification(approval, moderator.ID, moderator.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		b.respondEphemeral(s, i, "ℹ️ This user is no longer awaiting approval.")
+		return
+	}
+	if err != nil {
+		LogError("Error approving %s: %v", user.DiscordUsername, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred. The request is still pending, please try again.")
+		return
+	}
+	if !claimed {
+		b.respondEphemeral(s, i, "ℹ️ Another moderator has already handled this request.")
+		return
+	}
+
+	b.grantVerifiedRoles(user, user.Teams)
+
+	b.audit(i, AuditActionApprove, user.DiscordID, user.DiscordUsername, "", auditState(user), auditState(&User{Verified: true, Teams: user.Teams}))
+	b.events.Publish(Event{
+		Type:      EventVerification,
+		UserID:    user.DiscordID,
+		Username:  user.DiscordUsername,
+		ActorID:   moderator.ID,
+		ActorName: moderator.Username,
+		Email:     user.Email,
+		Team:      user.TeamNames(),
+	})
+
+	LogSuccess("User %s approved by %s (email: %s)", user.DiscordUsername, moderator.Username, user.Email)
+	b.updateApprovalMessage(s, i, approval, user.Teams, fmt.Sprintf("✅ Approved by <@%s>", moderator.ID))
+	b.SendDM(user.DiscordID, b.verificationCompleteDM(user.Teams))
+}
+
+func (b *Bot) denyVerification(s *discordgo.Session, i *discordgo.InteractionCreate, approval *VerificationApproval, user *User) {
+	moderator := i.Member.User
+
+	claimed, err := b.db.DecideVerificationApproval(approval.ID, ApprovalDenied, moderator.ID, moderator.Username)
+	if err != nil {
+		LogError("Error denying %s: %v", user.DiscordUsername, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+	if !claimed {
+		b.respondEphemeral(s, i, "ℹ️ Another moderator has already handled this request.")
+		return
+	}
+
+	if err := b.db.DeleteAwaitingUser(user.DiscordID); err != nil {
+		LogError("Error removing denied user %s: %v", user.DiscordUsername, err)
+	}
+
+	b.audit(i, AuditActionDeny, user.DiscordID, user.DiscordUsername, "", auditState(user), "none")
+
+	LogInfo("User %s denied by %s (email: %s)", user.DiscordUsername, moderator.Username, user.Email)
+	b.updateApprovalMessage(s, i, approval, user.Teams, fmt.Sprintf("❌ Denied by <@%s>", moderator.ID))
+	b.SendDM(user.DiscordID, "❌ A moderator has declined your verification. Please contact a moderator if you think this is a mistake.")
+}
+
+// updateApprovalMessage replaces the approval message's status and removes its buttons
+func (b *Bot) updateApprovalMessage(s *discordgo.Session, i *discordgo.InteractionCreate, approval *VerificationApproval, teams []string, status string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{b.approvalEmbed(approval, teams, status)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		LogError("Error updating message for approval %d: %v", approval.ID, err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// submitTestApproval creates a pending user and puts them in the approval queue
+func submitTestApproval(t *testing.T, db *Database, discordID string) *VerificationApproval {
+	t.Helper()
+	if err := db.CreateUser(discordID, discordID, discordID+"@company.com", discordID+"@company.com", "code-"+discordID); err != nil {
+		t.Fatalf("CreateUser(%s): %v", discordID, err)
+	}
+	user, err := db.GetUserByDiscordID(discordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.SubmitForApproval(user, nil)
+	if err != nil {
+		t.Fatalf("SubmitForApproval(%s): %v", discordID, err)
+	}
+	approval, err := db.GetVerificationApproval(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return approval
+}
+
+func TestApproveVerification(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	approval := submitTestApproval(t, db, "alice")
+	claimed, err := db.ApproveVerification(approval, "1", "mod")
+	if err != nil || !claimed {
+		t.Fatalf("ApproveVerification = %t, %v, want true", claimed, err)
+	}
+	user, err := db.GetUserByDiscordID("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Verified || user.AwaitingApproval {
+		t.Errorf("approved user should be verified: %+v", user)
+	}
+	if claimed, err := db.ApproveVerification(approval, "2", "other-mod"); err != nil || claimed {
+		t.Errorf("second ApproveVerification = %t, %v, want false", claimed, err)
+	}
+
+	// If the user can't be verified the approval stays pending for another try
+	approval = submitTestApproval(t, db, "bob")
+	if err := db.MarkUserVerified("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.db.Exec(`UPDATE users SET awaiting_approval = FALSE WHERE discord_id = 'bob'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ApproveVerification(approval, "1", "mod"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ApproveVerification for a user no longer awaiting approval = %v, want sql.ErrNoRows", err)
+	}
+	approval, err = db.GetVerificationApproval(approval.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval.Status != ApprovalPending || approval.DecidedByID != "" {
+		t.Errorf("failed approval should be rolled back to pending: %+v", approval)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// AuditAction identifies the kind of moderator action recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionReset        AuditAction = "reset"
+	AuditActionManualVerify AuditAction = "verify"
+	AuditActionChangeTeam   AuditAction = "changeteam"
+	AuditActionRestrict     AuditAction = "restrict"
+	AuditActionUnrestrict   AuditAction = "unrestrict"
+	AuditActionPurge        AuditAction = "purge"
+	AuditActionResend       AuditAction = "resend"
+	AuditActionSync         AuditAction = "sync"
+	AuditActionDomainAdd    AuditAction = "domain_add"
+	AuditActionDomainRemove AuditAction = "domain_remove"
+
+	AuditActionAllowlistAdd    AuditAction = "allowlist_add"
+	AuditActionAllowlistRemove AuditAction = "allowlist_remove"
+	AuditActionBlocklistAdd    AuditAction = "blocklist_add"
+	AuditActionBlocklistRemove AuditAction = "blocklist_remove"
+
+	AuditActionApprove     AuditAction = "approve"
+	AuditActionDeny        AuditAction = "deny"
+	AuditActionTeamApprove AuditAction = "team_approve"
+	AuditActionTeamDeny    AuditAction = "team_deny"
+
+	AuditActionReverifyLapsed AuditAction = "reverify_lapsed"
+)
+
+// auditActions lists every action, in the order offered by /heimdall-audit
+var auditActions = []AuditAction{
+	AuditActionReset,
+	AuditActionManualVerify,
+	AuditActionChangeTeam,
+	AuditActionRestrict,
+	AuditActionUnrestrict,
+	AuditActionPurge,
+	AuditActionResend,
+	AuditActionSync,
+	AuditActionDomainAdd,
+	AuditActionDomainRemove,
+	AuditActionAllowlistAdd,
+	AuditActionAllowlistRemove,
+	AuditActionBlocklistAdd,
+	AuditActionBlocklistRemove,
+	AuditActionApprove,
+	AuditActionDeny,
+	AuditActionTeamApprove,
+	AuditActionTeamDeny,
+	AuditActionReverifyLapsed,
+}
+
+const auditPageSize = 10
+
+// auditActionChoices returns the action filter choices for /heimdall-audit
+func auditActionChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(auditActions))
+	for _, action := range auditActions {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  string(action),
+			Value: string(action),
+		})
+	}
+	return choices
+}
+
+type AuditEntry struct {
+	ID          int64
+	Action      AuditAction
+	ActorID     string
+	ActorName   string
+	TargetID    string
+	TargetName  string
+	Reason      string
+	BeforeState string
+	AfterState  string
+	CreatedAt   time.Time
+}
+
+// AuditFilter narrows an audit log query; zero values match everything
+type AuditFilter struct {
+	TargetID string
+	ActorID  string
+	Actions  []AuditAction // Matches any of these actions
+	Since    *time.Time
+	Until    *time.Time
+}
+
+func (d *Database) RecordAudit(entry AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (action, actor_id, actor_name, target_id, target_name, reason, before_state, after_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := d.db.Exec(query, string(entry.Action), entry.ActorID, entry.ActorName,
+		entry.TargetID, entry.TargetName, entry.Reason, entry.BeforeState, entry.AfterState)
+	return err
+}
+
+// QueryAudit returns one page of matching audit entries, newest first, and the total number of matches
+func (d *Database) QueryAudit(filter AuditFilter, limit, offset int) ([]AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.ActorID != "" {
+		conditions = append(conditions, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if len(filter.Actions) > 0 {
+		placeholders := make([]string, len(filter.Actions))
+		for n, action := range filter.Actions {
+			placeholders[n] = "?"
+			args = append(args, string(action))
+		}
+		conditions = append(conditions, "action IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM audit_log `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, action, actor_id, actor_name, target_id, target_name,
+		       COALESCE(reason, ''), COALESCE(before_state, ''), COALESCE(after_state, ''), created_at
+		FROM audit_log ` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := d.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var action string
+		err := rows.Scan(&entry.ID, &action, &entry.ActorID, &entry.ActorName, &entry.TargetID, &entry.TargetName,
+			&entry.Reason, &entry.BeforeState, &entry.AfterState, &entry.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.Action = AuditAction(action)
+		entries = append(entries, entry)
+	}
+
+	return entries, total, rows.Err()
+}
+
+// auditState summarises a user's verification state for the audit log
+func auditState(user *User) string {
+	if user == nil {
+		return "none"
+	}
+
+	state := "pending"
+	if user.Unverified && user.RestrictedUntil != nil {
+		state = "restricted until " + user.RestrictedUntil.UTC().Format("2006-01-02 15:04 UTC")
+	} else if user.Unverified {
+		state = "restricted"
+	} else if user.Verified {
+		state = "verified"
+	} else if user.AwaitingApproval {
+		state = "awaiting approval"
+	} else if user.ReverifyLapsed {
+		state = "reverification lapsed"
+	}
+
+	switch len(user.Teams) {
+	case 0:
+	case 1:
+		state += fmt.Sprintf(" (team: %s)", user.Teams[0])
+	default:
+		state += fmt.Sprintf(" (teams: %s)", user.TeamNames())
+	}
+	return state
+}
+
+// audit records a moderator action taken through a slash command. Failures are
+// logged but never fail the command, since the action itself has already happened.
+func (b *Bot) audit(i *discordgo.InteractionCreate, action AuditAction, targetID, targetName, reason, before, after string) {
+	b.recordAudit(AuditEntry{
+		Action:      action,
+		ActorID:     i.Member.User.ID,
+		ActorName:   i.Member.User.Username,
+		TargetID:    targetID,
+		TargetName:  targetName,
+		Reason:      reason,
+		BeforeState: before,
+		AfterState:  after,
+	})
+}
+
+// auditAutomatic records an action Heimdall took on its own, e.g. lifting an
+// expired restriction, with the bot itself as the actor
+func (b *Bot) auditAutomatic(action AuditAction, targetID, targetName, reason, before, after string) {
+	entry := AuditEntry{
+		Action:      action,
+		ActorName:   "Heimdall",
+		TargetID:    targetID,
+		TargetName:  targetName,
+		Reason:      reason,
+		BeforeState: before,
+		AfterState:  after,
+	}
+	if b.session.State != nil && b.session.State.User != nil {
+		entry.ActorID = b.session.State.User.ID
+		entry.ActorName = b.session.State.User.Username
+	}
+	b.recordAudit(entry)
+}
+
+func (b *Bot) recordAudit(entry AuditEntry) {
+	if err := b.db.RecordAudit(entry); err != nil {
+		LogError("Error recording %s audit entry for %s: %v", entry.Action, entry.TargetName, err)
+	}
+}
+
+func (b *Bot) handleAudit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	var filter AuditFilter
+	page := 1
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "user":
+			filter.TargetID = opt.UserValue(s).ID
+		case "moderator":
+			filter.ActorID = opt.UserValue(s).ID
+		case "action":
+			filter.Actions = []AuditAction{AuditAction(opt.StringValue())}
+		case "since":
+			since, err := time.Parse("2006-01-02", opt.StringValue())
+			if err != nil {
+				b.respondEphemeral(s, i, "❌ Invalid `since` date. Use the format YYYY-MM-DD.")
+				return
+			}
+			filter.Since = &since
+		case "until":
+			until, err := time.Parse("2006-01-02", opt.StringValue())
+			if err != nil {
+				b.respondEphemeral(s, i, "❌ Invalid `until` date. Use the format YYYY-MM-DD.")
+				return
+			}
+			// Include the whole of the given day
+			until = until.Add(24 * time.Hour)
+			filter.Until = &until
+		case "page":
+			page = int(opt.IntValue())
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	LogDebug("Moderator %s requested audit log page %d", i.Member.User.Username, page)
+
+	entries, total, err := b.db.QueryAudit(filter, auditPageSize, (page-1)*auditPageSize)
+	if err != nil {
+		LogError("Error querying audit log: %v", err)
+		b.respondEphemeral(s, i, "❌ Error retrieving audit log.")
+		return
+	}
+
+	if total == 0 {
+		b.respondEphemeral(s, i, "No audit log entries match those filters.")
+		return
+	}
+
+	totalPages := (total + auditPageSize - 1) / auditPageSize
+	if len(entries) == 0 {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ Page %d doesn't exist. There are %d pages.", page, totalPages))
+		return
+	}
+
+	var description strings.Builder
+	for _, entry := range entries {
+		description.WriteString(fmt.Sprintf("`#%d` %s • **%s** • %s by %s\n",
+			entry.ID, entry.CreatedAt.Format("2006-01-02 15:04"), entry.Action,
+			auditUserLabel(entry.TargetID, entry.TargetName), auditUserLabel(entry.ActorID, entry.ActorName)))
+		if entry.Reason != "" {
+			description.WriteString(fmt.Sprintf("└ Reason: %s\n", truncate(entry.Reason, 200)))
+		}
+		if entry.BeforeState != "" || entry.AfterState != "" {
+			description.WriteString(fmt.Sprintf("└ %s → %s\n", entry.BeforeState, entry.AfterState))
+		}
+		description.WriteString("\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "📜 Audit Log",
+		Description: description.String(),
+		Color:       0x667eea,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d of %d • %d entries", page, totalPages, total),
+		},
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// auditUserLabel renders a user reference that still reads sensibly after they leave the server
+func auditUserLabel(id, name string) string {
+	if id == "" {
+		return name
+	}
+	return fmt.Sprintf("<@%s> (%s)", id, name)
+}
+
+// truncate shortens s to at most max runes, adding an ellipsis if anything was cut
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
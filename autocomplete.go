@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxAutocompleteChoices is the most suggestions Discord will show
+const maxAutocompleteChoices = 25
+
+// teamOption builds a team option whose values are suggested from Config.Teams as the moderator types
+func teamOption(description string, required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "team",
+		Description:  description,
+		Required:     required,
+		Autocomplete: true,
+	}
+}
+
+// onAutocomplete answers Discord's requests for suggestions while an option is being typed
+func (b *Bot) onAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	// /heimdall-myteam is the only command regular members can use
+	allowed := b.isAdmin(i.Member) || data.Name == "heimdall-myteam"
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	if focused := focusedOption(data.Options); focused != nil && allowed {
+		switch focused.Name {
+		case "team":
+			choices = b.teamChoices(data, i.Member.User.ID, focused.StringValue())
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		LogDebug("Error sending autocomplete results for /%s: %v", data.Name, err)
+	}
+}
+
+// focusedOption finds the option the user is typing in, looking inside subcommands
+func focusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, opt := range options {
+		if opt.Focused {
+			return opt
+		}
+		if found := focusedOption(opt.Options); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// teamChoices suggests configured teams containing typed, case-insensitively.
+// For /heimdall-changeteam and /heimdall-myteam, removing only offers the
+// user's current teams and adding leaves them out. /heimdall-myteam also only
+// offers the teams the caller's email domain allows.
+func (b *Bot) teamChoices(data discordgo.ApplicationCommandInteractionData, callerID, typed string) []*discordgo.ApplicationCommandOptionChoice {
+	teams := sortedKeys(b.config().Teams)
+
+	if data.Name == "heimdall-changeteam" || data.Name == "heimdall-myteam" {
+		var userID, action string
+		if data.Name == "heimdall-myteam" {
+			userID = callerID
+		}
+		for _, opt := range data.Options {
+			switch opt.Name {
+			case "user":
+				userID, _ = opt.Value.(string)
+			case "action":
+				action = opt.StringValue()
+			}
+		}
+		if user, err := b.db.GetUserByDiscordID(userID); userID != "" && err == nil {
+			if data.Name == "heimdall-myteam" {
+				teams = b.config().DomainSettingsFor(user.Email).TeamChoices(b.config().Teams)
+			}
+			switch action {
+			case "remove":
+				teams = slices.DeleteFunc(teams, func(team string) bool { return !user.HasTeam(team) })
+			case "add":
+				teams = slices.DeleteFunc(teams, user.HasTeam)
+			}
+		}
+	}
+
+	typed = strings.ToLower(strings.TrimSpace(typed))
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, team := range teams {
+		if !strings.Contains(strings.ToLower(team), typed) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: team, Value: team})
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+	}
+	return choices
+}
@@ -4,23 +4,31 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
+	"math"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type Bot struct {
 	session      *discordgo.Session
-	config       *Config
+	configs      *ConfigStore
 	db           *Database
 	emailService *EmailService
+	events       *EventBus
+	resolver     MXResolver // Used for verification.check_mx
 	ready        chan bool
+	reconcileMu  sync.Mutex // Prevents overlapping role syncs
+	roster       rosterState
 }
 
-func NewBot(token string, config *Config, db *Database, emailService *EmailService) (*Bot, error) {
+func NewBot(token string, configs *ConfigStore, db *Database, emailService *EmailService, events *EventBus) (*Bot, error) {
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
@@ -28,9 +36,11 @@ func NewBot(token string, config *Config, db *Database, emailService *EmailServi
 
 	bot := &Bot{
 		session:      session,
-		config:       config,
+		configs:      configs,
 		db:           db,
 		emailService: emailService,
+		events:       events,
+		resolver:     net.DefaultResolver,
 		ready:        make(chan bool, 1),
 	}
 
@@ -49,6 +59,11 @@ func NewBot(token string, config *Config, db *Database, emailService *EmailServi
 	return bot, nil
 }
 
+// config returns the live configuration; see ConfigStore
+func (b *Bot) config() *Config {
+	return b.configs.Get()
+}
+
 func (b *Bot) Start() error {
 	if err := b.session.Open(); err != nil {
 		return err
@@ -101,22 +116,18 @@ func (b *Bot) onGuildMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd
 		LogInfo("Restoring roles for returning verified user: %s", username)
 
 		// Assign base members role (if configured)
-		if b.config.Discord.MembersRole != "" {
-			if err := b.AssignRole(m.User.ID, b.config.Discord.MembersRole); err != nil {
+		if b.config().Discord.MembersRole != "" {
+			if err := b.AssignRole(m.User.ID, b.config().Discord.MembersRole); err != nil {
 				LogError("Error re-assigning members role to %s: %v", username, err)
 			} else {
 				LogDebug("Assigned members role to %s", username)
 			}
 		}
 
-		// Assign team role
-		if roleID, exists := b.config.Teams[user.TeamRole]; exists {
-			if err := b.AssignRole(m.User.ID, roleID); err != nil {
-				LogError("Error re-assigning team role to %s: %v", username, err)
-			} else {
-				LogDebug("Assigned %s team role to %s", user.TeamRole, username)
-			}
-		}
+		// Assign team roles
+		b.assignTeamRoles(m.User.ID, user.Teams, username)
+
+		b.assignDomainRoles(m.User.ID, user.Email, username)
 		return
 	}
 
@@ -129,7 +140,7 @@ func (b *Bot) onGuildMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd
 	}
 
 	// Use configured welcome message, or fallback to default if not set
-	welcomeMsg := b.config.Discord.WelcomeMessage
+	welcomeMsg := b.config().Discord.WelcomeMessage
 	if welcomeMsg == "" {
 		welcomeMsg = `👋 Welcome to the server!
 
@@ -182,25 +193,100 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
+	if err == nil && user.AwaitingApproval {
+		LogDebug("User %s is awaiting approval, ignoring DM", username)
+		s.ChannelMessageSend(m.ChannelID, "🛂 Your email is verified and a moderator is reviewing your request. I'll message you once they've decided.")
+		return
+	}
+
+	isResend := strings.EqualFold(strings.TrimSpace(m.Content), "resend")
+
+	if err == nil && user.ReverifyLapsed && !isResend {
+		LogDebug("User %s has a lapsed reverification, ignoring DM", username)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("⌛ Your server access is paused because you didn't confirm your work email in time. Click the link in the email sent to **%s** to restore it, or reply **resend** for a new link.", user.Email))
+		return
+	}
+
+	// Let pending and lapsed users request a fresh verification link
+	if isResend {
+		if err == sql.ErrNoRows {
+			s.ChannelMessageSend(m.ChannelID, "❌ You haven't started verification yet. Please reply with your work email address.")
+			return
+		}
+		if err != nil {
+			LogError("Error getting user %s for resend: %v", username, err)
+			s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
+			return
+		}
+
+		if reason := b.checkResendAllowed(user); reason != "" {
+			LogDebug("Resend refused for %s: %s", username, reason)
+			s.ChannelMessageSend(m.ChannelID, "⏳ "+reason)
+			return
+		}
+
+		if err := b.resendVerificationEmail(user); err != nil {
+			LogError("Error resending verification email to %s: %v", user.Email, err)
+			s.ChannelMessageSend(m.ChannelID, "❌ Failed to send verification email. Please contact an administrator.")
+			return
+		}
+
+		LogSuccess("Verification email resent to %s (user: %s)", user.Email, username)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("✅ A new verification email has been sent to **%s**! Your previous link no longer works.", user.Email))
+		return
+	}
+
+	// A pending user whose link has expired can start over without waiting for the janitor
+	if err == nil && user.CodeExpired(b.config().CodeTTL()) {
+		if _, err := b.db.DeletePendingUser(user.DiscordID); err != nil {
+			LogError("Error removing expired pending user %s: %v", username, err)
+			s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
+			return
+		}
+		LogInfo("Removed expired pending entry for %s so they can start over", username)
+		user = nil
+	}
+
 	// Validate email format
-	email := strings.TrimSpace(strings.ToLower(m.Content))
-	if !isValidEmail(email) {
-		LogDebug("Invalid email format from %s: %s", username, email)
+	email, err := normalizeEmail(m.Content)
+	if err != nil {
+		LogDebug("Invalid email format from %s: %s (%v)", username, m.Content, err)
 		s.ChannelMessageSend(m.ChannelID, "❌ That doesn't look like a valid email address. Please try again.")
 		return
 	}
 
 	LogInfo("Processing verification request from %s with email: %s", username, email)
 
-	// Check if email domain is approved
-	if !b.isApprovedDomain(email) {
+	// Check the address against the blocklist, allowlist and approved domains
+	switch b.checkEmailAccess(email) {
+	case emailBlocked:
+		LogWarn("Rejected blocklisted email: %s (user: %s)", email, username)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ Sorry, %s can't be used for verification. Please contact a moderator if you think this is a mistake.", email))
+		return
+	case emailNotApproved:
 		LogWarn("Rejected email from unapproved domain: %s (user: %s)", email, username)
 		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ Sorry, the domain for %s is not approved. Please use your work email from an approved company domain.", email))
 		return
+	case emailCheckFailed:
+		s.ChannelMessageSend(m.ChannelID, "❌ We couldn't check your email address right now. Please try again in a few minutes.")
+		return
+	}
+
+	if !b.domainAcceptsMail(email) {
+		LogWarn("Rejected email for domain without a mail server: %s (user: %s)", email, username)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("❌ The domain for %s can't receive email. Please check the address for typos.", email))
+		return
 	}
 
-	// Check if email already exists
-	exists, err := b.db.EmailExists(email)
+	// Pending users can correct the address they submitted
+	if user != nil && user.IsPending() {
+		b.changePendingEmail(s, m, user, email, username)
+		return
+	}
+
+	// Check if email already exists, counting variants like plus tags as the same address
+	emailCanonical := b.config().CanonicalEmail(email)
+	exists, err := b.db.EmailExists(emailCanonical)
 	if err != nil {
 		LogError("Error checking email existence for %s: %v", email, err)
 		s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
@@ -221,7 +307,7 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 	}
 	if exists {
 		LogDebug("User %s already has verification in progress", username)
-		s.ChannelMessageSend(m.ChannelID, "❌ You've already started the verification process. Please check your email for the verification link.")
+		s.ChannelMessageSend(m.ChannelID, "❌ You've already started the verification process. Please check your email for the verification link, or reply **resend** to get a new one.")
 		return
 	}
 
@@ -234,7 +320,7 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 	}
 
 	// Create user in database
-	err = b.db.CreateUser(m.Author.ID, username, email, verificationCode)
+	err = b.db.CreateUser(m.Author.ID, username, email, emailCanonical, verificationCode)
 	if err != nil {
 		LogError("Error creating user %s in database: %v", username, err)
 		s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
@@ -252,7 +338,9 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 
 	LogSuccess("Verification email sent to %s (user: %s)", email, username)
 	successMsg := fmt.Sprintf("✅ Verification email sent to **%s**!\n\nPlease check your inbox and click the verification link.", email)
-	if b.config.Features.EnableTeamSelection {
+	if lockedTeam := b.config().DomainSettingsFor(email).LockedTeam; b.config().Features.EnableTeamSelection && lockedTeam != "" {
+		successMsg += fmt.Sprintf(" Once you verify, you'll join the %s team and have full access to the server.", lockedTeam)
+	} else if b.config().Features.EnableTeamSelection {
 		successMsg += " You'll be asked to select your team, and then you'll have full access to the server."
 	} else {
 		successMsg += " Once you verify, you'll have full access to the server."
@@ -269,6 +357,46 @@ func (b *Bot) registerCommands() error {
 		{
 			Name:        "heimdall-list",
 			Description: "List all users and their verification status (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "status",
+					Description: "Only show users with this status",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "pending", Value: "pending"},
+						{Name: "awaiting approval", Value: "awaiting"},
+						{Name: "reverification lapsed", Value: "lapsed"},
+						{Name: "verified", Value: "verified"},
+						{Name: "restricted", Value: "restricted"},
+					},
+				},
+				teamOption("Only show users on this team", false),
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "domain",
+					Description: "Only show users with this email domain",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "joined_after",
+					Description: "Only show users who joined on or after this date (YYYY-MM-DD)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "joined_before",
+					Description: "Only show users who joined on or before this date (YYYY-MM-DD)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "page",
+					Description: "Page number (default: 1)",
+					Required:    false,
+				},
+			},
 		},
 		{
 			Name:        "heimdall-reset",
@@ -298,6 +426,12 @@ func (b *Bot) registerCommands() error {
 					Description: "Reason for restriction (optional)",
 					Required:    false,
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "Lift automatically after e.g. 12h, 7d or 2w (default: until unrestricted)",
+					Required:    false,
+				},
 			},
 		},
 		{
@@ -312,9 +446,64 @@ func (b *Bot) registerCommands() error {
 				},
 			},
 		},
+		{
+			Name:        "heimdall-resend",
+			Description: "Resend a pending user's verification email (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "The pending user to resend to",
+					Required:    true,
+				},
+			},
+		},
 		{
 			Name:        "heimdall-domains",
-			Description: "List approved email domains (Moderator only)",
+			Description: "Manage approved email domains (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "List approved email domains",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Approve a domain (e.g. company.com, *.company.com or !contractors.company.com)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "domain",
+							Description: "Domain or pattern to add",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Remove a domain added with /heimdall-domains add",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "domain",
+							Description: "Domain or pattern to remove",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "heimdall-allow",
+			Description: "Let specific email addresses verify regardless of domain (Moderator only)",
+			Options:     emailRuleCommandOptions("Allow"),
+		},
+		{
+			Name:        "heimdall-block",
+			Description: "Stop specific email addresses from verifying (Moderator only)",
+			Options:     emailRuleCommandOptions("Block"),
 		},
 		{
 			Name:        "heimdall-purge",
@@ -334,6 +523,85 @@ func (b *Bot) registerCommands() error {
 				},
 			},
 		},
+		{
+			Name:        "heimdall-lookup",
+			Description: "Show one user's full record (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "Look up by Discord account",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "email",
+					Description: "Look up by email address",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "username",
+					Description: "Look up by Discord username",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "heimdall-sync",
+			Description: "Reconcile Discord roles with the database (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "dry_run",
+					Description: "Only report drift without changing any roles",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "heimdall-audit",
+			Description: "View the moderator audit log (Moderator only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "Only show actions taken on this user",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "moderator",
+					Description: "Only show actions taken by this moderator",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "action",
+					Description: "Only show this type of action",
+					Required:    false,
+					Choices:     auditActionChoices(),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "since",
+					Description: "Only show actions on or after this date (YYYY-MM-DD)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "until",
+					Description: "Only show actions on or before this date (YYYY-MM-DD)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "page",
+					Description: "Page number (default: 1)",
+					Required:    false,
+				},
+			},
+		},
 		{
 			Name:        "heimdall-help",
 			Description: "Show help information",
@@ -356,13 +624,8 @@ func (b *Bot) registerCommands() error {
 		},
 	}
 
-	if b.config.Features.EnableTeamSelection {
-		verifyCommandOptions = append(verifyCommandOptions, &discordgo.ApplicationCommandOption{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "team",
-			Description: "Team to assign",
-			Required:    true,
-		})
+	if b.config().Features.EnableTeamSelection {
+		verifyCommandOptions = append(verifyCommandOptions, teamOption("Team to assign", true))
 	}
 
 	commands = append(commands, &discordgo.ApplicationCommand{
@@ -372,10 +635,10 @@ func (b *Bot) registerCommands() error {
 	})
 
 	// Only register changeteam command if feature is enabled
-	if b.config.Features.EnableTeamSelection {
+	if b.config().Features.EnableTeamSelection {
 		commands = append(commands, &discordgo.ApplicationCommand{
 			Name:        "heimdall-changeteam",
-			Description: "Change a verified user's team (Moderator only)",
+			Description: "Change a verified user's teams (Moderator only)",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionUser,
@@ -383,11 +646,33 @@ func (b *Bot) registerCommands() error {
 					Description: "The user to change",
 					Required:    true,
 				},
+				teamOption("Team to set, add or remove", true),
+				teamActionOption(),
+			},
+		})
+
+		if b.config().TeamChanges.Enabled {
+			commands = append(commands, &discordgo.ApplicationCommand{
+				Name:        "heimdall-myteam",
+				Description: "Switch, join or leave a team",
+				Options: []*discordgo.ApplicationCommandOption{
+					teamOption("Team to switch to, join or leave", true),
+					teamActionOption(),
+				},
+			})
+		}
+	}
+
+	if b.config().Reverification.Enabled {
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Name:        "heimdall-reverification",
+			Description: "See who is waiting to reconfirm their email or coming due",
+			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "team",
-					Description: "New team to assign",
-					Required:    true,
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: "How many days ahead to look (default: 30)",
+					Required:    false,
 				},
 			},
 		})
@@ -395,7 +680,7 @@ func (b *Bot) registerCommands() error {
 
 	// Use bulk overwrite to avoid rate limits - this replaces ALL commands in one API call
 	log.Printf("Registering %d commands using bulk overwrite...", len(commands))
-	registeredCommands, err := b.session.ApplicationCommandBulkOverwrite(b.session.State.User.ID, b.config.Discord.GuildID, commands)
+	registeredCommands, err := b.session.ApplicationCommandBulkOverwrite(b.session.State.User.ID, b.config().Discord.GuildID, commands)
 	if err != nil {
 		return fmt.Errorf("failed to register commands: %v", err)
 	}
@@ -409,10 +694,32 @@ func (b *Bot) registerCommands() error {
 }
 
 func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.Type != discordgo.InteractionApplicationCommand {
-		return
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.onApplicationCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		b.onAutocomplete(s, i)
+	case discordgo.InteractionMessageComponent:
+		b.onMessageComponent(s, i)
 	}
+}
+
+func (b *Bot) onMessageComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
 
+	switch {
+	case strings.HasPrefix(customID, listCustomIDPrefix):
+		b.handleListPage(s, i)
+	case strings.HasPrefix(customID, teamRequestCustomIDPrefix):
+		b.handleTeamRequestButton(s, i)
+	case strings.HasPrefix(customID, approvalCustomIDPrefix):
+		b.handleApprovalButton(s, i)
+	case strings.HasPrefix(customID, rosterCustomIDPrefix):
+		b.handleRosterButton(s, i)
+	}
+}
+
+func (b *Bot) onApplicationCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	switch i.ApplicationCommandData().Name {
 	case "heimdall-stats":
 		b.handleStats(s, i)
@@ -424,14 +731,30 @@ func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.Interaction
 		b.handleManualVerify(s, i)
 	case "heimdall-changeteam":
 		b.handleChangeTeam(s, i)
+	case "heimdall-myteam":
+		b.handleMyTeam(s, i)
 	case "heimdall-restrict":
 		b.handleRestrict(s, i)
 	case "heimdall-unrestrict":
 		b.handleUnrestrict(s, i)
+	case "heimdall-resend":
+		b.handleResend(s, i)
 	case "heimdall-domains":
 		b.handleDomains(s, i)
+	case "heimdall-allow":
+		b.handleEmailRule(s, i, EmailRuleAllow)
+	case "heimdall-block":
+		b.handleEmailRule(s, i, EmailRuleBlock)
 	case "heimdall-purge":
 		b.handlePurge(s, i)
+	case "heimdall-lookup":
+		b.handleLookup(s, i)
+	case "heimdall-sync":
+		b.handleSync(s, i)
+	case "heimdall-audit":
+		b.handleAudit(s, i)
+	case "heimdall-reverification":
+		b.handleReverificationReport(s, i)
 	case "heimdall-help":
 		b.handleHelp(s, i)
 	}
@@ -485,57 +808,6 @@ func (b *Bot) handleStats(s *discordgo.Session, i *discordgo.InteractionCreate)
 	})
 }
 
-func (b *Bot) handleList(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.isAdmin(i.Member) {
-		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
-		return
-	}
-
-	LogDebug("Moderator %s requested user list", i.Member.User.Username)
-
-	users, err := b.db.GetAllUsers()
-	if err != nil {
-		LogError("Error getting users: %v", err)
-		b.respondEphemeral(s, i, "❌ Error retrieving user list.")
-		return
-	}
-
-	if len(users) == 0 {
-		LogDebug("User list empty")
-		b.respondEphemeral(s, i, "No users in the database yet.")
-		return
-	}
-
-	LogInfo("User list retrieved: %d users", len(users))
-
-	var description strings.Builder
-	for _, user := range users {
-		status := "⏳ Pending"
-		if user.Verified {
-			status = fmt.Sprintf("✅ Verified (%s)", user.TeamRole)
-		} else if user.Unverified {
-			status = fmt.Sprintf("⚠️ Unverified (was %s)", user.TeamRole)
-		}
-
-		description.WriteString(fmt.Sprintf("**%s**\n└ %s\n└ %s\n\n",
-			user.DiscordUsername, user.Email, status))
-	}
-
-	embed := &discordgo.MessageEmbed{
-		Title:       "👥 User List",
-		Description: description.String(),
-		Color:       0x667eea,
-	}
-
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-			Flags:  discordgo.MessageFlagsEphemeral,
-		},
-	})
-}
-
 func (b *Bot) handleReset(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	if !b.isAdmin(i.Member) {
 		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
@@ -561,23 +833,19 @@ func (b *Bot) handleReset(s *discordgo.Session, i *discordgo.InteractionCreate)
 
 	// Remove roles if verified
 	if user.Verified {
-		// Remove team role
-		if roleID, exists := b.config.Teams[user.TeamRole]; exists {
-			if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, userOption.ID, roleID); err != nil {
-				LogWarn("Error removing team role from %s during reset: %v", userOption.Username, err)
-			} else {
-				LogDebug("Removed %s team role from %s", user.TeamRole, userOption.Username)
-			}
-		}
+		// Remove team roles
+		b.removeTeamRoles(userOption.ID, user.Teams, userOption.Username)
 
 		// Remove members role (if configured)
-		if b.config.Discord.MembersRole != "" {
-			if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, userOption.ID, b.config.Discord.MembersRole); err != nil {
+		if b.config().Discord.MembersRole != "" {
+			if err := s.GuildMemberRoleRemove(b.config().Discord.GuildID, userOption.ID, b.config().Discord.MembersRole); err != nil {
 				LogWarn("Error removing members role from %s during reset: %v", userOption.Username, err)
 			} else {
 				LogDebug("Removed members role from %s", userOption.Username)
 			}
 		}
+
+		b.removeDomainRoles(userOption.ID, user.Email, userOption.Username)
 	}
 
 	// Delete user from database
@@ -588,6 +856,16 @@ func (b *Bot) handleReset(s *discordgo.Session, i *discordgo.InteractionCreate)
 		return
 	}
 
+	b.audit(i, AuditActionReset, userOption.ID, userOption.Username, "", auditState(user), auditState(nil))
+	b.events.Publish(Event{
+		Type:      EventReset,
+		UserID:    userOption.ID,
+		Username:  userOption.Username,
+		ActorID:   i.Member.User.ID,
+		ActorName: i.Member.User.Username,
+		Team:      user.TeamNames(),
+	})
+
 	LogSuccess("User %s reset by moderator %s", userOption.Username, i.Member.User.Username)
 	b.respondEphemeral(s, i, fmt.Sprintf("✅ Reset verification for <@%s>. They can now start the verification process again.", userOption.ID))
 
@@ -595,30 +873,61 @@ func (b *Bot) handleReset(s *discordgo.Session, i *discordgo.InteractionCreate)
 	b.SendDM(userOption.ID, "Your verification has been reset by an administrator. Please send me your work email address to start the verification process again.")
 }
 
-func (b *Bot) handleDomains(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (b *Bot) handleResend(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	if !b.isAdmin(i.Member) {
 		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
 		return
 	}
 
-	var domainList strings.Builder
-	for _, domain := range b.config.ApprovedDomains {
-		domainList.WriteString(fmt.Sprintf("• %s\n", domain))
+	options := i.ApplicationCommandData().Options
+	userOption := options[0].UserValue(s)
+
+	LogInfo("Moderator %s attempting to resend verification email to: %s", i.Member.User.Username, userOption.Username)
+
+	user, err := b.db.GetUserByDiscordID(userOption.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> hasn't started verification yet.", userOption.ID))
+		} else {
+			LogError("Error getting user for resend %s: %v", userOption.Username, err)
+			b.respondEphemeral(s, i, "❌ Database error occurred.")
+		}
+		return
 	}
 
-	embed := &discordgo.MessageEmbed{
-		Title:       "📧 Approved Email Domains",
-		Description: domainList.String(),
-		Color:       0x667eea,
+	if user.Verified {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already verified.", userOption.ID))
+		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-			Flags:  discordgo.MessageFlagsEphemeral,
-		},
-	})
+	if user.Unverified {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is restricted. Use `/heimdall-unrestrict` to restore access.", userOption.ID))
+		return
+	}
+
+	if user.AwaitingApproval {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> has already verified their email and is waiting for a moderator's approval in <#%s>.", userOption.ID, b.config().Discord.ApprovalChannelID))
+		return
+	}
+
+	if reason := b.checkResendAllowed(user); reason != "" {
+		LogDebug("Resend refused for %s: %s", userOption.Username, reason)
+		b.respondEphemeral(s, i, "⏳ "+reason)
+		return
+	}
+
+	if err := b.resendVerificationEmail(user); err != nil {
+		LogError("Error resending verification email to %s: %v", user.Email, err)
+		b.respondEphemeral(s, i, "❌ Failed to send verification email.")
+		return
+	}
+
+	b.audit(i, AuditActionResend, userOption.ID, userOption.Username, "", auditState(user), auditState(user))
+
+	LogSuccess("Verification email resent to %s (user: %s) by moderator %s", user.Email, userOption.Username, i.Member.User.Username)
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ Sent a new verification email to `%s` for <@%s>.", user.Email, userOption.ID))
+
+	b.SendDM(userOption.ID, fmt.Sprintf("📧 A moderator has sent you a new verification email at **%s**. Your previous link no longer works.", user.Email))
 }
 
 func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -630,12 +939,15 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 	options := i.ApplicationCommandData().Options
 	userOption := options[0].UserValue(s)
 	email := strings.TrimSpace(strings.ToLower(options[1].StringValue()))
+	if normalized, err := normalizeEmail(email); err == nil {
+		email = normalized
+	}
 
 	var team string
 	var roleID string
 
 	// Get team parameter if team selection is enabled
-	if b.config.Features.EnableTeamSelection {
+	if b.config().Features.EnableTeamSelection {
 		team = options[2].StringValue()
 		LogInfo("Moderator %s attempting manual verify: user=%s email=%s team=%s", i.Member.User.Username, userOption.Username, email, team)
 	} else {
@@ -649,17 +961,25 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 		return
 	}
 
-	// Check if domain is approved
-	if !b.isApprovedDomain(email) {
+	// Check the address against the blocklist, allowlist and approved domains
+	switch b.checkEmailAccess(email) {
+	case emailBlocked:
+		LogWarn("Rejected blocklisted email in manual verify: %s (moderator: %s)", email, i.Member.User.Username)
+		b.respondEphemeral(s, i, "❌ This email address is on the blocklist. Remove it with `/heimdall-block remove` first.")
+		return
+	case emailNotApproved:
 		LogWarn("Rejected unapproved domain in manual verify: %s (moderator: %s)", email, i.Member.User.Username)
-		b.respondEphemeral(s, i, "❌ Domain not approved. Email must be from an approved domain.")
+		b.respondEphemeral(s, i, "❌ Domain not approved. Email must be from an approved domain or on the allowlist.")
+		return
+	case emailCheckFailed:
+		b.respondEphemeral(s, i, "❌ Couldn't check the allowlist and blocklist. Please try again.")
 		return
 	}
 
 	// Check if team exists (only if feature is enabled)
-	if b.config.Features.EnableTeamSelection {
+	if b.config().Features.EnableTeamSelection {
 		var exists bool
-		roleID, exists = b.config.Teams[team]
+		roleID, exists = b.config().Teams[team]
 		if !exists {
 			LogDebug("Invalid team selected in manual verify: %s", team)
 			b.respondEphemeral(s, i, fmt.Sprintf("❌ Team '%s' not found.\n\n**Available teams:** %s", team, b.getTeamNames()))
@@ -671,8 +991,8 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 	existingUser, err := b.db.GetUserByDiscordID(userOption.ID)
 	if err == nil && existingUser.Verified {
 		LogDebug("User %s already verified, manual verify rejected", userOption.Username)
-		if b.config.Features.EnableTeamSelection {
-			b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already verified. Use `/heimdall-changeteam` to change their team.", userOption.ID))
+		if b.config().Features.EnableTeamSelection {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already verified. Use `/heimdall-changeteam` to change their teams.", userOption.ID))
 		} else {
 			b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already verified.", userOption.ID))
 		}
@@ -680,7 +1000,8 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 	}
 
 	// Check if email is already used
-	emailExists, err := b.db.EmailExists(email)
+	emailCanonical := b.config().CanonicalEmail(email)
+	emailExists, err := b.db.EmailExists(emailCanonical)
 	if err != nil {
 		LogError("Error checking email existence in manual verify: %v", err)
 		b.respondEphemeral(s, i, "❌ Database error occurred.")
@@ -711,7 +1032,7 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 		b.db.DeleteUser(userOption.ID)
 	}
 
-	err = b.db.CreateUser(userOption.ID, username, email, verificationCode)
+	err = b.db.CreateUser(userOption.ID, username, email, emailCanonical, verificationCode)
 	if err != nil {
 		LogError("Error creating user in manual verify %s: %v", username, err)
 		b.respondEphemeral(s, i, "❌ Error creating user in database.")
@@ -719,8 +1040,8 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 	}
 
 	// Mark as verified in database
-	if b.config.Features.EnableTeamSelection {
-		err = b.db.UpdateUserTeam(userOption.ID, team)
+	if b.config().Features.EnableTeamSelection {
+		err = b.db.VerifyUserWithTeams(userOption.ID, []string{team})
 		if err != nil {
 			LogError("Error updating user team in manual verify %s: %v", username, err)
 			b.respondEphemeral(s, i, "❌ Error updating user verification.")
@@ -735,17 +1056,30 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 		}
 	}
 
+	b.audit(i, AuditActionManualVerify, userOption.ID, username, "", auditState(existingUser), auditState(&User{Verified: true, Teams: []string{team}}))
+	b.events.Publish(Event{
+		Type:      EventVerification,
+		UserID:    userOption.ID,
+		Username:  username,
+		ActorID:   i.Member.User.ID,
+		ActorName: i.Member.User.Username,
+		Email:     email,
+		Team:      team,
+	})
+
 	// Assign members role (if configured)
-	if b.config.Discord.MembersRole != "" {
-		if err := b.AssignRole(userOption.ID, b.config.Discord.MembersRole); err != nil {
+	if b.config().Discord.MembersRole != "" {
+		if err := b.AssignRole(userOption.ID, b.config().Discord.MembersRole); err != nil {
 			LogError("Error assigning members role in manual verify %s: %v", username, err)
 		} else {
 			LogDebug("Assigned members role to %s (manual verify)", username)
 		}
 	}
 
+	b.assignDomainRoles(userOption.ID, email, username)
+
 	// Assign team role (only if feature is enabled)
-	if b.config.Features.EnableTeamSelection {
+	if b.config().Features.EnableTeamSelection {
 		if err := b.AssignRole(userOption.ID, roleID); err != nil {
 			LogError("Error assigning team role in manual verify %s: %v", username, err)
 			b.respondEphemeral(s, i, "⚠️ User verified in database but failed to assign Discord role. Please assign manually.")
@@ -755,7 +1089,7 @@ func (b *Bot) handleManualVerify(s *discordgo.Session, i *discordgo.InteractionC
 	}
 
 	// Send success message
-	if b.config.Features.EnableTeamSelection {
+	if b.config().Features.EnableTeamSelection {
 		LogSuccess("Manual verification: %s verified by %s (team: %s, email: %s)", username, i.Member.User.Username, team, email)
 		b.respondEphemeral(s, i, fmt.Sprintf("✅ Successfully verified <@%s> with email `%s` and assigned to **%s** team.", userOption.ID, email, team))
 		b.SendDM(userOption.ID, fmt.Sprintf("✅ You have been manually verified by a moderator! Welcome to the **%s** team. You now have access to the server.", team))
@@ -772,17 +1106,26 @@ func (b *Bot) handleChangeTeam(s *discordgo.Session, i *discordgo.InteractionCre
 		return
 	}
 
-	options := i.ApplicationCommandData().Options
-	userOption := options[0].UserValue(s)
-	newTeam := options[1].StringValue()
+	var userOption *discordgo.User
+	var team string
+	action := TeamActionSet
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "user":
+			userOption = opt.UserValue(s)
+		case "team":
+			team = opt.StringValue()
+		case "action":
+			action = opt.StringValue()
+		}
+	}
 
-	LogInfo("Moderator %s attempting team change: user=%s newTeam=%s", i.Member.User.Username, userOption.Username, newTeam)
+	LogInfo("Moderator %s attempting team change: user=%s action=%s team=%s", i.Member.User.Username, userOption.Username, action, team)
 
-	// Check if new team exists
-	newRoleID, exists := b.config.Teams[newTeam]
-	if !exists {
-		LogDebug("Invalid team in change team: %s", newTeam)
-		b.respondEphemeral(s, i, fmt.Sprintf("❌ Team '%s' not found.\n\n**Available teams:** %s", newTeam, b.getTeamNames()))
+	// Check if team exists
+	if _, exists := b.config().Teams[team]; !exists {
+		LogDebug("Invalid team in change team: %s", team)
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ Team '%s' not found.\n\n**Available teams:** %s", team, b.getTeamNames()))
 		return
 	}
 
@@ -805,46 +1148,50 @@ func (b *Bot) handleChangeTeam(s *discordgo.Session, i *discordgo.InteractionCre
 		return
 	}
 
-	oldTeam := user.TeamRole
+	oldTeams := user.Teams
 
-	// Check if already on this team
-	if oldTeam == newTeam {
-		LogDebug("User %s already on team %s", userOption.Username, newTeam)
-		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already on the **%s** team.", userOption.ID, newTeam))
+	switch err := checkTeamChange(user, action, team); err {
+	case errAlreadyOnTeam:
+		LogDebug("User %s already on team %s", userOption.Username, team)
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is already on the **%s** team.", userOption.ID, team))
+		return
+	case errNotOnTeam:
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ <@%s> is not on the **%s** team.", userOption.ID, team))
+		return
+	case errLastTeam:
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ **%s** is <@%s>'s only team. Use `action:set` to move them to a different team.", team, userOption.ID))
 		return
 	}
 
-	// Remove old team role
-	if oldRoleID, exists := b.config.Teams[oldTeam]; exists {
-		if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, userOption.ID, oldRoleID); err != nil {
-			LogWarn("Error removing old team role from %s: %v", userOption.Username, err)
-		} else {
-			LogDebug("Removed %s team role from %s", oldTeam, userOption.Username)
-		}
-	}
-
-	// Assign new team role
-	if err := b.AssignRole(userOption.ID, newRoleID); err != nil {
-		LogError("Error assigning new team role to %s: %v", userOption.Username, err)
+	newTeams, err := b.applyTeamChange(user, action, team)
+	if errors.Is(err, errTeamRoleFailed) {
+		LogError("Error assigning %s team role to %s: %v", team, userOption.Username, err)
 		b.respondEphemeral(s, i, "⚠️ Failed to assign new Discord role. Please assign manually.")
 		return
 	}
-	LogDebug("Assigned %s team role to %s", newTeam, userOption.Username)
-
-	// Update database
-	err = b.db.UpdateUserTeam(userOption.ID, newTeam)
 	if err != nil {
-		LogError("Error updating user team in database %s: %v", userOption.Username, err)
+		LogError("Error updating user teams in database %s: %v", userOption.Username, err)
 		b.respondEphemeral(s, i, "⚠️ Role changed in Discord but database update failed.")
 		return
 	}
 
+	b.audit(i, AuditActionChangeTeam, userOption.ID, userOption.Username, "", auditState(user), auditState(&User{Verified: true, Teams: newTeams}))
+	b.events.Publish(Event{
+		Type:         EventTeamChange,
+		UserID:       userOption.ID,
+		Username:     userOption.Username,
+		ActorID:      i.Member.User.ID,
+		ActorName:    i.Member.User.Username,
+		Team:         strings.Join(newTeams, ", "),
+		PreviousTeam: strings.Join(oldTeams, ", "),
+	})
+
 	// Send success message
-	LogSuccess("Team change: %s moved from %s to %s by %s", userOption.Username, oldTeam, newTeam, i.Member.User.Username)
-	b.respondEphemeral(s, i, fmt.Sprintf("✅ Changed <@%s> from **%s** to **%s** team.", userOption.ID, oldTeam, newTeam))
+	LogSuccess("Team change: %s moved from [%s] to [%s] by %s", userOption.Username, strings.Join(oldTeams, ", "), strings.Join(newTeams, ", "), i.Member.User.Username)
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ <@%s> is now on %s (was %s).", userOption.ID, teamPhrase(newTeams), teamPhrase(oldTeams)))
 
 	// Send DM to user
-	b.SendDM(userOption.ID, fmt.Sprintf("📝 Your team has been changed from **%s** to **%s** by a moderator.", oldTeam, newTeam))
+	b.SendDM(userOption.ID, fmt.Sprintf("📝 A moderator changed your teams. You're now on %s (previously %s).", teamPhrase(newTeams), teamPhrase(oldTeams)))
 }
 
 func (b *Bot) handleRestrict(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -853,12 +1200,28 @@ func (b *Bot) handleRestrict(s *discordgo.Session, i *discordgo.InteractionCreat
 		return
 	}
 
-	options := i.ApplicationCommandData().Options
-	userOption := options[0].UserValue(s)
+	var userOption *discordgo.User
+	var reason, duration string
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "user":
+			userOption = opt.UserValue(s)
+		case "reason":
+			reason = opt.StringValue()
+		case "duration":
+			duration = strings.TrimSpace(strings.ToLower(opt.StringValue()))
+		}
+	}
 
-	var reason string
-	if len(options) > 1 {
-		reason = options[1].StringValue()
+	var until *time.Time
+	if duration != "" {
+		length, err := parseRestrictionDuration(duration)
+		if err != nil {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ Invalid duration: %v", err))
+			return
+		}
+		end := time.Now().Add(length)
+		until = &end
 	}
 
 	// Get user from database
@@ -883,40 +1246,59 @@ func (b *Bot) handleRestrict(s *discordgo.Session, i *discordgo.InteractionCreat
 		return
 	}
 
-	// Remove team role
-	if roleID, exists := b.config.Teams[user.TeamRole]; exists {
-		if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, userOption.ID, roleID); err != nil {
-			log.Printf("Error removing team role: %v", err)
-		}
-	}
+	// Remove team roles
+	b.removeTeamRoles(userOption.ID, user.Teams, userOption.Username)
 
 	// Remove members role (if configured)
-	if b.config.Discord.MembersRole != "" {
-		if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, userOption.ID, b.config.Discord.MembersRole); err != nil {
+	if b.config().Discord.MembersRole != "" {
+		if err := s.GuildMemberRoleRemove(b.config().Discord.GuildID, userOption.ID, b.config().Discord.MembersRole); err != nil {
 			log.Printf("Error removing members role: %v", err)
 		}
 	}
 
+	b.removeDomainRoles(userOption.ID, user.Email, userOption.Username)
+
 	// Mark as unverified in database
-	if err := b.db.UnverifyUser(userOption.ID); err != nil {
+	if err := b.db.UnverifyUser(userOption.ID, until); err != nil {
 		log.Printf("Error restricting user: %v", err)
 		b.respondEphemeral(s, i, "❌ Failed to update database.")
 		return
 	}
 
+	b.audit(i, AuditActionRestrict, userOption.ID, userOption.Username, reason, auditState(user), auditState(&User{Unverified: true, Teams: user.Teams, RestrictedUntil: until}))
+	b.events.Publish(Event{
+		Type:      EventRestriction,
+		UserID:    userOption.ID,
+		Username:  userOption.Username,
+		ActorID:   i.Member.User.ID,
+		ActorName: i.Member.User.Username,
+		Team:      user.TeamNames(),
+		Reason:    reason,
+		Until:     until,
+	})
+
 	// Send success message to moderator
+	response := fmt.Sprintf("✅ Restricted <@%s>.", userOption.ID)
 	if reason != "" {
-		b.respondEphemeral(s, i, fmt.Sprintf("✅ Restricted <@%s>.\n**Reason:** %s\n\nUser has been notified and their roles removed. Use `/heimdall-unrestrict` to restore access.", userOption.ID, reason))
+		response += fmt.Sprintf("\n**Reason:** %s", reason)
+	}
+	if until != nil {
+		response += fmt.Sprintf("\n**Ends:** %s\n\nUser has been notified and their roles removed. Access is restored automatically when the restriction ends, or use `/heimdall-unrestrict` to restore it sooner.", discordTimestamp(*until))
 	} else {
-		b.respondEphemeral(s, i, fmt.Sprintf("✅ Restricted <@%s>.\n\nUser has been notified and their roles removed. Use `/heimdall-unrestrict` to restore access.", userOption.ID))
+		response += "\n\nUser has been notified and their roles removed. Use `/heimdall-unrestrict` to restore access."
 	}
+	b.respondEphemeral(s, i, response)
 
 	// Send DM to user
 	dmMessage := "⚠️ Your server access has been temporarily restricted by a moderator."
 	if reason != "" {
 		dmMessage += fmt.Sprintf("\n**Reason:** %s", reason)
 	}
-	dmMessage += "\n\nYou cannot use the automatic verification system. Please contact a moderator to restore your account."
+	if until != nil {
+		dmMessage += fmt.Sprintf("\n\nYour access will be restored automatically on %s. You cannot use the automatic verification system until then.", discordTimestamp(*until))
+	} else {
+		dmMessage += "\n\nYou cannot use the automatic verification system. Please contact a moderator to restore your account."
+	}
 	b.SendDM(userOption.ID, dmMessage)
 }
 
@@ -946,20 +1328,10 @@ func (b *Bot) handleUnrestrict(s *discordgo.Session, i *discordgo.InteractionCre
 		return
 	}
 
-	// Assign members role (if configured)
-	if b.config.Discord.MembersRole != "" {
-		if err := b.AssignRole(userOption.ID, b.config.Discord.MembersRole); err != nil {
-			log.Printf("Error assigning members role: %v", err)
-		}
-	}
-
-	// Assign team role
-	if roleID, exists := b.config.Teams[user.TeamRole]; exists {
-		if err := b.AssignRole(userOption.ID, roleID); err != nil {
-			log.Printf("Error assigning team role: %v", err)
-			b.respondEphemeral(s, i, "⚠️ Failed to assign Discord roles. Please assign manually.")
-			return
-		}
+	// Restore members, domain and team roles
+	if err := b.grantVerifiedRoles(user, user.Teams); err != nil {
+		b.respondEphemeral(s, i, "⚠️ Failed to assign Discord roles. Please assign manually.")
+		return
 	}
 
 	// Mark as unrestricted in database
@@ -969,11 +1341,21 @@ func (b *Bot) handleUnrestrict(s *discordgo.Session, i *discordgo.InteractionCre
 		return
 	}
 
+	b.audit(i, AuditActionUnrestrict, userOption.ID, userOption.Username, "", auditState(user), auditState(&User{Verified: true, Teams: user.Teams}))
+	b.events.Publish(Event{
+		Type:      EventUnrestrict,
+		UserID:    userOption.ID,
+		Username:  userOption.Username,
+		ActorID:   i.Member.User.ID,
+		ActorName: i.Member.User.Username,
+		Team:      user.TeamNames(),
+	})
+
 	// Send success message
-	b.respondEphemeral(s, i, fmt.Sprintf("✅ Removed restrictions from <@%s> on the **%s** team. Their access has been restored.", userOption.ID, user.TeamRole))
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ Removed restrictions from <@%s> on %s. Their access has been restored.", userOption.ID, teamPhrase(user.Teams)))
 
 	// Send DM to user
-	b.SendDM(userOption.ID, fmt.Sprintf("✅ Your server access has been restored by a moderator! Welcome back to the **%s** team.", user.TeamRole))
+	b.SendDM(userOption.ID, fmt.Sprintf("✅ Your server access has been restored by a moderator! Welcome back to %s.", teamPhrase(user.Teams)))
 }
 
 func (b *Bot) handlePurge(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -1006,8 +1388,11 @@ func (b *Bot) handlePurge(s *discordgo.Session, i *discordgo.InteractionCreate)
 			LogInfo("Moderator %s attempting purge for Discord user: %s (ID: %s)", i.Member.User.Username, userOption.Username, userOption.ID)
 			break
 		} else if opt.Name == "email" {
-			identifier = opt.StringValue()
-			user, err = b.db.GetUserByEmail(identifier)
+			identifier = strings.TrimSpace(strings.ToLower(opt.StringValue()))
+			if normalized, err := normalizeEmail(identifier); err == nil {
+				identifier = normalized
+			}
+			user, err = b.db.GetUserByEmail(identifier, b.config().CanonicalEmail(identifier))
 			identifierType = "email"
 			LogInfo("Moderator %s attempting purge for email: %s", i.Member.User.Username, identifier)
 			break
@@ -1029,28 +1414,24 @@ func (b *Bot) handlePurge(s *discordgo.Session, i *discordgo.InteractionCreate)
 	discordID := user.DiscordID
 	discordUsername := user.DiscordUsername
 	email := user.Email
-	teamRole := user.TeamRole
+	teams := user.Teams
 	isVerified := user.Verified
 
 	// Remove roles if verified
 	if isVerified {
-		// Remove team role
-		if roleID, exists := b.config.Teams[teamRole]; exists {
-			if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, discordID, roleID); err != nil {
-				LogWarn("Error removing team role from %s during purge: %v", discordUsername, err)
-			} else {
-				LogDebug("Removed %s team role from %s", teamRole, discordUsername)
-			}
-		}
+		// Remove team roles
+		b.removeTeamRoles(discordID, teams, discordUsername)
 
 		// Remove members role (if configured)
-		if b.config.Discord.MembersRole != "" {
-			if err := s.GuildMemberRoleRemove(b.config.Discord.GuildID, discordID, b.config.Discord.MembersRole); err != nil {
+		if b.config().Discord.MembersRole != "" {
+			if err := s.GuildMemberRoleRemove(b.config().Discord.GuildID, discordID, b.config().Discord.MembersRole); err != nil {
 				LogWarn("Error removing members role from %s during purge: %v", discordUsername, err)
 			} else {
 				LogDebug("Removed members role from %s", discordUsername)
 			}
 		}
+
+		b.removeDomainRoles(discordID, email, discordUsername)
 	}
 
 	// Delete user from database (GDPR compliance - complete data removal)
@@ -1061,6 +1442,16 @@ func (b *Bot) handlePurge(s *discordgo.Session, i *discordgo.InteractionCreate)
 		return
 	}
 
+	// Only the verification state is recorded, never the purged email address
+	b.audit(i, AuditActionPurge, discordID, discordUsername, "", auditState(user), auditState(nil))
+	b.events.Publish(Event{
+		Type:      EventPurge,
+		UserID:    discordID,
+		Username:  discordUsername,
+		ActorID:   i.Member.User.ID,
+		ActorName: i.Member.User.Username,
+	})
+
 	LogSuccess("User purged: %s (Email: %s) by moderator %s", discordUsername, email, i.Member.User.Username)
 	b.respondEphemeral(s, i, fmt.Sprintf("✅ User data purged successfully.\n\n**User:** %s\n**Email:** %s\n**Discord ID:** %s\n\nAll user data has been permanently removed from the database.", discordUsername, email, discordID))
 
@@ -1078,7 +1469,7 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:  "🆕 New Users",
-				Value: "When you join the server, I'll send you a DM. Simply reply with your work email address, and I'll send you a verification link. Click the link, select your team, and you're all set!",
+				Value: "When you join the server, I'll send you a DM. Simply reply with your work email address, and I'll send you a verification link. Click the link, select your team, and you're all set!\n\nLost the email or the link expired? Reply **resend** in our DMs to get a new one.",
 			},
 			{
 				Name:  "📧 Email Requirements",
@@ -1087,13 +1478,22 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		},
 	}
 
+	if b.config().Features.EnableTeamSelection && b.config().TeamChanges.Enabled {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "👥 Changing Teams",
+			Value: "Use `/heimdall-myteam` to switch to another team, or `action:add` / `action:remove` to join or leave one.",
+		})
+	}
+
 	if isAdmin {
-		embed.Fields = append(embed.Fields, []*discordgo.MessageEmbedField{
-			{
-				Name:  "🔧 Moderator Commands",
-				Value: "`/heimdall-stats` - View verification statistics\n`/heimdall-list` - List all users\n`/heimdall-reset` - Reset a user's verification\n`/heimdall-verify` - Manually verify a user\n`/heimdall-changeteam` - Change a user's team\n`/heimdall-restrict` - Temporarily restrict a user's access\n`/heimdall-unrestrict` - Remove restrictions from a user\n`/heimdall-purge` - Permanently delete user data (GDPR)\n`/heimdall-domains` - View approved domains",
-			},
-		}...)
+		modCommands := "`/heimdall-stats` - View verification statistics\n`/heimdall-list` - List all users\n`/heimdall-lookup` - Inspect one user's record\n`/heimdall-reset` - Reset a user's verification\n`/heimdall-resend` - Resend a pending user's verification email\n`/heimdall-verify` - Manually verify a user\n`/heimdall-changeteam` - Set, add or remove a user's teams\n`/heimdall-restrict` - Temporarily restrict a user's access\n`/heimdall-unrestrict` - Remove restrictions from a user\n`/heimdall-purge` - Permanently delete user data (GDPR)\n`/heimdall-domains` - List, add or remove approved domains\n`/heimdall-allow` / `/heimdall-block` - Manage individual email addresses\n`/heimdall-audit` - View the moderator audit log\n`/heimdall-sync` - Reconcile Discord roles with the database"
+		if b.config().Reverification.Enabled {
+			modCommands += "\n`/heimdall-reverification` - See who is due to reconfirm their email"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "🔧 Moderator Commands",
+			Value: modCommands,
+		})
 	}
 
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -1106,12 +1506,12 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 func (b *Bot) isAdmin(member *discordgo.Member) bool {
-	if b.config.Discord.AdminRole == "" {
+	if b.config().Discord.AdminRole == "" {
 		return false
 	}
 
 	for _, roleID := range member.Roles {
-		if roleID == b.config.Discord.AdminRole {
+		if roleID == b.config().Discord.AdminRole {
 			return true
 		}
 	}
@@ -1136,7 +1536,7 @@ func (b *Bot) respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCre
 }
 
 func (b *Bot) AssignRole(userID, roleID string) error {
-	return b.session.GuildMemberRoleAdd(b.config.Discord.GuildID, userID, roleID)
+	return b.session.GuildMemberRoleAdd(b.config().Discord.GuildID, userID, roleID)
 }
 
 func (b *Bot) SendDM(userID, message string) error {
@@ -1155,19 +1555,116 @@ func (b *Bot) isApprovedDomain(email string) bool {
 		return false
 	}
 
-	domain := strings.ToLower(parts[1])
-	for _, approvedDomain := range b.config.ApprovedDomains {
-		if strings.ToLower(approvedDomain) == domain {
-			return true
+	return isDomainApproved(parts[1], b.approvedDomainPatterns())
+}
+
+// checkResendAllowed returns the reason a user can't be sent another verification email yet, or "" if they can
+func (b *Bot) checkResendAllowed(user *User) string {
+	if wait := b.config().ResendCooldown() - time.Since(user.CodeIssuedAt); wait > 0 {
+		return fmt.Sprintf("A verification email was sent recently. Another can be sent in %s.", formatWait(wait))
+	}
+
+	return b.checkResendCap(user)
+}
+
+// checkResendCap returns the reason a user has used up their daily resends, or "" if they haven't
+func (b *Bot) checkResendCap(user *User) string {
+	if user.ResendsInWindow() >= b.config().ResendDailyCap() {
+		wait := 24*time.Hour - time.Since(*user.ResendWindowAt)
+		return fmt.Sprintf("The daily limit of %d resends has been reached. Another can be sent in %s.", b.config().ResendDailyCap(), formatWait(wait))
+	}
+
+	return ""
+}
+
+// changePendingEmail replaces the address on a pending user's record and sends a fresh link to it.
+// Only the daily cap applies here: the cooldown protects a single inbox, and a typo fix goes to a new one.
+func (b *Bot) changePendingEmail(s *discordgo.Session, m *discordgo.MessageCreate, user *User, email, username string) {
+	if email == user.Email {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("📧 A verification link was already sent to **%s**. Please check your inbox, or reply **resend** to get a new one.", email))
+		return
+	}
+
+	LogInfo("Pending user %s requested email change from %s to %s", username, user.Email, email)
+
+	// The user's own record only matches if the new address is a variant of their current one
+	emailCanonical := b.config().CanonicalEmail(email)
+	exists, err := b.db.EmailExists(emailCanonical)
+	if err != nil {
+		LogError("Error checking email existence for %s: %v", email, err)
+		s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
+		return
+	}
+	if exists && emailCanonical != user.EmailCanonical {
+		LogWarn("Duplicate email in email change: %s (user: %s)", email, username)
+		s.ChannelMessageSend(m.ChannelID, "❌ This email address is already registered. Each email can only be used once.")
+		return
+	}
+
+	if reason := b.checkResendCap(user); reason != "" {
+		LogDebug("Email change refused for %s: %s", username, reason)
+		s.ChannelMessageSend(m.ChannelID, "⏳ "+reason)
+		return
+	}
+
+	verificationCode, err := generateVerificationCode()
+	if err != nil {
+		LogError("Error generating verification code for %s: %v", username, err)
+		s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
+		return
+	}
+
+	if err := b.db.UpdatePendingEmail(user.DiscordID, email, emailCanonical, verificationCode); err != nil {
+		LogError("Error updating email for %s: %v", username, err)
+		s.ChannelMessageSend(m.ChannelID, "❌ An error occurred. Please try again later.")
+		return
+	}
+
+	if err := b.emailService.SendVerificationEmail(email, verificationCode, m.Author.Username); err != nil {
+		LogError("Error sending verification email to %s: %v", email, err)
+		s.ChannelMessageSend(m.ChannelID, "❌ Failed to send verification email. Please contact an administrator.")
+		return
+	}
+
+	LogSuccess("Email changed for pending user %s from %s to %s", username, user.Email, email)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("✅ Updated your email to **%s** and sent a new verification link. The link sent to %s no longer works.", email, user.Email))
+}
+
+// resendVerificationEmail issues a new code for a pending user and emails it, invalidating the old link
+func (b *Bot) resendVerificationEmail(user *User) error {
+	verificationCode, err := generateVerificationCode()
+	if err != nil {
+		return err
+	}
+
+	// Members whose reverification lapsed confirm their existing address instead of verifying from scratch
+	if user.ReverifyLapsed {
+		if err := b.db.RegenerateReverificationCode(user.DiscordID, verificationCode); err != nil {
+			return err
 		}
+		return b.emailService.SendReverificationEmail(user.Email, verificationCode, user.DiscordUsername, nil)
 	}
 
-	return false
+	if err := b.db.RegenerateVerificationCode(user.DiscordID, verificationCode); err != nil {
+		return err
+	}
+
+	return b.emailService.SendVerificationEmail(user.Email, verificationCode, user.DiscordUsername)
 }
 
-func isValidEmail(email string) bool {
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-	return emailRegex.MatchString(email)
+// formatWait renders a wait time rounded up to whole minutes, e.g. "4 minutes" or "2h 15m"
+func formatWait(d time.Duration) string {
+	minutes := int(math.Ceil(d.Minutes()))
+	if minutes < 1 {
+		minutes = 1
+	}
+	if minutes == 1 {
+		return "1 minute"
+	}
+	if minutes < 60 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
 }
 
 func generateVerificationCode() (string, error) {
@@ -1179,8 +1676,8 @@ func generateVerificationCode() (string, error) {
 }
 
 func (b *Bot) getTeamNames() string {
-	teams := make([]string, 0, len(b.config.Teams))
-	for team := range b.config.Teams {
+	teams := make([]string, 0, len(b.config().Teams))
+	for _, team := range sortedKeys(b.config().Teams) {
 		teams = append(teams, fmt.Sprintf("`%s`", team))
 	}
 	return strings.Join(teams, ", ")
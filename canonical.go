@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// CanonicalEmail reduces an address to the form used for uniqueness checks,
+// so that variants delivering to the same inbox count as one address.
+// The raw address is still what gets stored and emailed.
+func (c *Config) CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if c.EmailNormalization.StripPlusTags {
+		if plus := strings.Index(local, "+"); plus > 0 {
+			local = local[:plus]
+		}
+	}
+
+	for _, pattern := range c.EmailNormalization.DotFoldingDomains {
+		if domainMatches(domain, strings.ToLower(pattern)) {
+			local = strings.ReplaceAll(local, ".", "")
+			break
+		}
+	}
+
+	return local + "@" + domain
+}
+
+// emailNormalizationChanged reports whether a reload changes how canonical addresses are computed
+func emailNormalizationChanged(old, new *Config) bool {
+	return old.EmailNormalization.StripPlusTags != new.EmailNormalization.StripPlusTags ||
+		!slices.Equal(old.EmailNormalization.DotFoldingDomains, new.EmailNormalization.DotFoldingDomains)
+}
+
+// RecanonicalizeEmails recomputes every user's canonical address after the
+// normalization settings change. Rows whose new canonical address would
+// collide with another user's keep their old one and are returned as
+// conflicts for a moderator to resolve.
+func (d *Database) RecanonicalizeEmails(canonicalize func(string) string) (updated int, conflicts []string, err error) {
+	rows, err := d.db.Query(`SELECT discord_id, email, COALESCE(email_canonical, '') FROM users`)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	type change struct{ discordID, email, canonical string }
+	var changes []change
+	for rows.Next() {
+		var discordID, email, current string
+		if err := rows.Scan(&discordID, &email, &current); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if canonical := canonicalize(email); canonical != current {
+			changes = append(changes, change{discordID, email, canonical})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	for _, c := range changes {
+		_, err := d.db.Exec(`UPDATE users SET email_canonical = ? WHERE discord_id = ?`, c.canonical, c.discordID)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				conflicts = append(conflicts, c.email)
+				continue
+			}
+			return updated, conflicts, err
+		}
+		updated++
+	}
+
+	return updated, conflicts, nil
+}
+
+// recanonicalizeEmails brings stored canonical addresses in line with the current config
+func (b *Bot) recanonicalizeEmails() {
+	updated, conflicts, err := b.db.RecanonicalizeEmails(b.config().CanonicalEmail)
+	if err != nil {
+		LogError("Error updating canonical email addresses: %v", err)
+		return
+	}
+	if updated > 0 {
+		LogInfo("Updated canonical email address for %d users", updated)
+	}
+	for _, email := range conflicts {
+		LogWarn("Email %s is a duplicate of another user's address under the current normalization settings; check with /heimdall-lookup", email)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestCanonicalEmail(t *testing.T) {
+	config := &Config{}
+	config.EmailNormalization.StripPlusTags = true
+	config.EmailNormalization.DotFoldingDomains = []string{"gmail.com", "*.example.org"}
+
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{"unchanged", "alice@company.com", "alice@company.com"},
+		{"lowercased and trimmed", "  Alice@Company.COM ", "alice@company.com"},
+		{"plus tag stripped", "alice+news@company.com", "alice@company.com"},
+		{"only the first plus tag counts", "alice+a+b@company.com", "alice@company.com"},
+		{"leading plus kept", "+alice@company.com", "+alice@company.com"},
+		{"dots kept outside folding domains", "alice.smith@company.com", "alice.smith@company.com"},
+		{"dots folded", "a.l.i.c.e@gmail.com", "alice@gmail.com"},
+		{"dots folded and plus tag stripped", "Alice.Smith+work@Gmail.com", "alicesmith@gmail.com"},
+		{"wildcard folding domain", "alice.smith@mail.example.org", "alicesmith@mail.example.org"},
+		{"wildcard doesn't cover the parent", "alice.smith@example.org", "alice.smith@example.org"},
+		{"no @", "Not An Address", "not an address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.CanonicalEmail(tt.email); got != tt.want {
+				t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalEmailDisabled(t *testing.T) {
+	config := &Config{}
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice+news@company.com", "alice+news@company.com"},
+		{"a.l.i.c.e@gmail.com", "a.l.i.c.e@gmail.com"},
+		{"Alice@Company.com", "alice@company.com"},
+	}
+
+	for _, tt := range tests {
+		if got := config.CanonicalEmail(tt.email); got != tt.want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const cliUsage = `Usage: heimdall [options] [command]
+
+Run without a command to start the bot.
+
+Options:
+  --config <path>     YAML config file (default: config.yaml)
+  --database <path>   SQLite database file (default: data/heimdall.db)
+
+Commands:
+  config check [--discord]   Validate the config file; --discord also checks the
+                             guild, roles and audit channel via the Discord API
+  migrate status             Show applied and pending database migrations
+  migrate up                 Apply pending database migrations and exit
+`
+
+// runCommand handles CLI subcommands and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "config":
+		return runConfig(args[1:])
+	case "migrate":
+		return runMigrate(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "check" || len(args) > 2 || (len(args) == 2 && args[1] != "--discord") {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	checkDiscord := len(args) == 2
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", configPath, err)
+		return 1
+	}
+
+	if err := config.Validate(); err != nil {
+		printConfigErrors(err.(ConfigErrors))
+		return 1
+	}
+
+	if checkDiscord {
+		fmt.Println("Checking Discord guild, roles and channels...")
+		if err := config.CheckDiscord(); err != nil {
+			printConfigErrors(err.(ConfigErrors))
+			return 1
+		}
+	}
+
+	fmt.Printf("✓ %s is valid\n", configPath)
+	return 0
+}
+
+func printConfigErrors(errs ConfigErrors) {
+	fmt.Fprintf(os.Stderr, "%s has %d problem(s):\n", configPath, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  ✗ %v\n", err)
+	}
+}
+
+func runMigrate(args []string) int {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	if _, err := os.Stat(databasePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database %s: %v\n", databasePath, err)
+		return 1
+	}
+
+	db, err := OpenDatabase(databasePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database %s: %v\n", databasePath, err)
+		return 1
+	}
+	defer db.Close()
+
+	if args[0] == "up" {
+		if err := db.Migrate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying migrations: %v\n", err)
+			return 1
+		}
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading migration status: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Database: %s\n\n", databasePath)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	pending := 0
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d of %d migrations applied, %d pending\n", len(statuses)-pending, len(statuses), pending)
+	return 0
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,6 +16,11 @@ type Config struct {
 		AdminRole      string `yaml:"admin_role"`
 		MembersRole    string `yaml:"members_role"`    // Base role assigned to all verified users
 		WelcomeMessage string `yaml:"welcome_message"` // Message sent to users when they join
+
+		AuditChannelID string          `yaml:"audit_channel_id"` // Channel where verification and moderation events are posted (optional)
+		AuditEvents    map[string]bool `yaml:"audit_events"`     // Per-event toggles for the audit channel (default: all enabled)
+
+		ApprovalChannelID string `yaml:"approval_channel_id"` // Channel where requests needing a moderator's approval are posted
 	} `yaml:"discord"`
 
 	Email struct {
@@ -32,12 +40,64 @@ type Config struct {
 
 	Features struct {
 		EnableTeamSelection bool `yaml:"enable_team_selection"` // Enable team/role selection during verification
+		RequireApproval     bool `yaml:"require_approval"`      // Hold verified users until a moderator approves them
 	} `yaml:"features"`
 
-	ApprovedDomains []string          `yaml:"approved_domains"`
-	Teams           map[string]string `yaml:"teams"` // team name -> role ID
+	EmailNormalization struct {
+		StripPlusTags     bool     `yaml:"strip_plus_tags"`     // Treat alice+tag@company.com as alice@company.com
+		DotFoldingDomains []string `yaml:"dot_folding_domains"` // Domains where dots in the local part are ignored, e.g. gmail.com
+	} `yaml:"email_normalization"`
+
+	Verification struct {
+		CodeExpiryHours        int  `yaml:"code_expiry_hours"`        // How long a verification link stays valid (default: 24)
+		CleanupIntervalMinutes int  `yaml:"cleanup_interval_minutes"` // How often expired pending users are purged (default: 15)
+		ResendCooldownMinutes  int  `yaml:"resend_cooldown_minutes"`  // Minimum time between verification emails (default: 5)
+		MaxResendsPerDay       int  `yaml:"max_resends_per_day"`      // Resends allowed per user in 24 hours (default: 3)
+		CheckMX                bool `yaml:"check_mx"`                 // Reject addresses whose domain has no mail server in DNS
+	} `yaml:"verification"`
+
+	RoleSync struct {
+		Enabled         bool `yaml:"enabled"`          // Periodically reconcile Discord roles with the database
+		IntervalMinutes int  `yaml:"interval_minutes"` // How often to reconcile (default: 60)
+		DryRun          bool `yaml:"dry_run"`          // Only report drift on scheduled runs, don't fix it
+	} `yaml:"role_sync"`
+
+	Reverification struct {
+		Enabled      bool `yaml:"enabled"`       // Periodically ask verified members to confirm their email again
+		IntervalDays int  `yaml:"interval_days"` // Days after verifying before a member must confirm again (default: 180)
+		GraceDays    int  `yaml:"grace_days"`    // Days to confirm before losing access (default: 7)
+	} `yaml:"reverification"`
+
+	Roster struct {
+		Enabled           bool     `yaml:"enabled"`             // Restrict verified members whose email is missing from the employee roster
+		File              string   `yaml:"file"`                // CSV or JSON roster export, reloaded when it changes
+		EmailColumn       string   `yaml:"email_column"`        // CSV column or JSON field holding the email (default: email)
+		UploadToken       string   `yaml:"upload_token"`        // Bearer token for POST /api/roster; uploads are refused when empty
+		Domains           []string `yaml:"domains"`             // Only check members whose email matches these patterns (default: all)
+		ApplyDelayMinutes int      `yaml:"apply_delay_minutes"` // Time moderators have to review the report before restrictions apply (default: 60)
+		DryRun            bool     `yaml:"dry_run"`             // Only post the report, never restrict anyone
+	} `yaml:"roster"`
+
+	TeamChanges struct {
+		Enabled         bool `yaml:"enabled"`          // Let verified users change their own teams with /heimdall-myteam
+		CooldownHours   int  `yaml:"cooldown_hours"`   // Minimum time between self-service changes (default: 24)
+		RequireApproval bool `yaml:"require_approval"` // Post changes to the approval channel for a moderator to approve
+	} `yaml:"team_changes"`
+
+	ApprovedDomains []string                  `yaml:"approved_domains"`
+	DomainSettings  map[string]DomainSettings `yaml:"domain_settings"` // approved_domains pattern -> team and role overrides
+	Teams           map[string]string         `yaml:"teams"`           // team name -> role ID
 }
 
+// DomainSettings customises verification for users from one domain
+type DomainSettings struct {
+	DefaultTeam string   `yaml:"default_team"` // Preselected on the verification page
+	LockedTeam  string   `yaml:"locked_team"`  // Always assigned; the user doesn't get a choice
+	Teams       []string `yaml:"teams"`        // Limits the teams offered (default: all teams)
+	ExtraRoles  []string `yaml:"extra_roles"`  // Role IDs granted in addition to the members and team roles
+}
+
+// LoadConfig reads the YAML config at path and applies any HEIMDALL_* environment overrides
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -49,5 +109,141 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := applyEnvOverrides(&config); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
-}
\ No newline at end of file
+}
+
+// DomainSettingsFor returns the settings for an email's domain. An exact
+// domain entry wins over wildcards, and the most specific wildcard wins
+// over broader ones. Domains without settings get the zero value.
+func (c *Config) DomainSettingsFor(email string) DomainSettings {
+	parts := strings.Split(strings.ToLower(email), "@")
+	if len(parts) != 2 {
+		return DomainSettings{}
+	}
+	domain := parts[1]
+
+	best, bestLen := "", -1
+	for pattern := range c.DomainSettings {
+		normalized := strings.ToLower(pattern)
+		if !strings.HasPrefix(normalized, "*.") && domainMatches(domain, normalized) {
+			return c.DomainSettings[pattern]
+		}
+		if domainMatches(domain, normalized) && len(normalized) > bestLen {
+			best, bestLen = pattern, len(normalized)
+		}
+	}
+	if bestLen < 0 {
+		return DomainSettings{}
+	}
+	return c.DomainSettings[best]
+}
+
+// TeamChoices returns the teams a user from a domain with these settings may pick, sorted
+func (ds DomainSettings) TeamChoices(teams map[string]string) []string {
+	if ds.LockedTeam != "" {
+		return []string{ds.LockedTeam}
+	}
+	if len(ds.Teams) == 0 {
+		return sortedKeys(teams)
+	}
+
+	var choices []string
+	for _, team := range ds.Teams {
+		if _, exists := teams[team]; exists {
+			choices = append(choices, team)
+		}
+	}
+	sort.Strings(choices)
+	return choices
+}
+
+// CodeTTL returns how long a verification code stays valid
+func (c *Config) CodeTTL() time.Duration {
+	if c.Verification.CodeExpiryHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.Verification.CodeExpiryHours) * time.Hour
+}
+
+// CleanupInterval returns how often the janitor purges expired pending users
+func (c *Config) CleanupInterval() time.Duration {
+	if c.Verification.CleanupIntervalMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.Verification.CleanupIntervalMinutes) * time.Minute
+}
+
+// ResendCooldown returns the minimum time between verification emails for one user
+func (c *Config) ResendCooldown() time.Duration {
+	if c.Verification.ResendCooldownMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.Verification.ResendCooldownMinutes) * time.Minute
+}
+
+// ResendDailyCap returns how many times a user may have their verification email resent in 24 hours
+func (c *Config) ResendDailyCap() int {
+	if c.Verification.MaxResendsPerDay <= 0 {
+		return 3
+	}
+	return c.Verification.MaxResendsPerDay
+}
+
+// AuditEventEnabled reports whether an event type should be posted to the audit channel.
+// Event types missing from discord.audit_events are enabled.
+func (c *Config) AuditEventEnabled(eventType EventType) bool {
+	enabled, ok := c.Discord.AuditEvents[string(eventType)]
+	return !ok || enabled
+}
+
+// TeamChangeCooldown returns the minimum time between a user's self-service team changes
+func (c *Config) TeamChangeCooldown() time.Duration {
+	if c.TeamChanges.CooldownHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.TeamChanges.CooldownHours) * time.Hour
+}
+
+// ReverificationInterval returns how long a verification lasts before the member must confirm again
+func (c *Config) ReverificationInterval() time.Duration {
+	if c.Reverification.IntervalDays <= 0 {
+		return 180 * 24 * time.Hour
+	}
+	return time.Duration(c.Reverification.IntervalDays) * 24 * time.Hour
+}
+
+// ReverificationGrace returns how long a member has to confirm before losing access
+func (c *Config) ReverificationGrace() time.Duration {
+	if c.Reverification.GraceDays <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.Reverification.GraceDays) * 24 * time.Hour
+}
+
+// RosterEmailColumn returns the roster column or field that holds each email
+func (c *Config) RosterEmailColumn() string {
+	if c.Roster.EmailColumn == "" {
+		return "email"
+	}
+	return c.Roster.EmailColumn
+}
+
+// RosterApplyDelay returns how long a roster report waits for moderators before restrictions apply
+func (c *Config) RosterApplyDelay() time.Duration {
+	if c.Roster.ApplyDelayMinutes <= 0 {
+		return 60 * time.Minute
+	}
+	return time.Duration(c.Roster.ApplyDelayMinutes) * time.Minute
+}
+
+// RoleSyncInterval returns how often scheduled role syncs run
+func (c *Config) RoleSyncInterval() time.Duration {
+	if c.RoleSync.IntervalMinutes <= 0 {
+		return 60 * time.Minute
+	}
+	return time.Duration(c.RoleSync.IntervalMinutes) * time.Minute
+}
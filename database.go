@@ -2,6 +2,11 @@ package main
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -12,211 +17,335 @@ type Database struct {
 }
 
 type User struct {
-	ID              int64
-	DiscordID       string
-	DiscordUsername string
-	Email           string
+	ID               int64
+	DiscordID        string
+	DiscordUsername  string
+	Email            string
+	EmailCanonical   string // Email reduced to its canonical form for uniqueness checks
 	VerificationCode string
-	TeamRole        string
-	Verified        bool
-	Unverified      bool // True if user has been unverified by moderator
-	CreatedAt       time.Time
-	VerifiedAt      *time.Time
+	Teams            []string // Team names, sorted
+	Verified         bool
+	Unverified       bool // True if user has been unverified by moderator
+	AwaitingApproval bool // Clicked their link but a moderator hasn't approved them yet
+	CreatedAt        time.Time
+	VerifiedAt       *time.Time
+	CodeIssuedAt     time.Time  // When the current verification code was generated
+	ResendCount      int        // Verification emails resent in the current 24h window
+	ResendWindowAt   *time.Time // Start of the current resend window
+	RestrictedUntil  *time.Time // When a time-limited restriction is lifted automatically
+	ReverifyAskedAt  *time.Time // When a verified member was last asked to confirm their email again
+	ReverifyLapsed   bool       // Didn't confirm their email in time; access paused until they do
 }
 
-func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// ErrVerificationCodeExpired is returned when a pending user's verification code is older than the configured TTL
+var ErrVerificationCodeExpired = errors.New("verification code expired")
+
+// userColumns is the column list shared by every query that loads a User
+const userColumns = `id, discord_id, discord_username, email, COALESCE(email_canonical, ''), verification_code,
+		       COALESCE((SELECT group_concat(team, char(31)) FROM user_teams WHERE user_teams.discord_id = users.discord_id), ''), verified, COALESCE(unverified, 0), COALESCE(awaiting_approval, 0), created_at, verified_at,
+		       code_issued_at, COALESCE(resend_count, 0), resend_window_at, restricted_until, reverify_requested_at,
+		       COALESCE(reverify_lapsed, 0)`
+
+// pendingCondition matches users who haven't clicked their verification link yet
+const pendingCondition = `verified = FALSE AND COALESCE(unverified, 0) = 0 AND COALESCE(awaiting_approval, 0) = 0 AND COALESCE(reverify_lapsed, 0) = 0`
+
+// teamSeparator joins a user's teams in userColumns (char(31), a control character no team name uses)
+const teamSeparator = "\x1f"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	var teams string
+	var verifiedAt, codeIssuedAt, resendWindowAt, restrictedUntil, reverifyAskedAt sql.NullTime
+
+	err := row.Scan(
+		&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email, &user.EmailCanonical,
+		&user.VerificationCode, &teams, &user.Verified, &user.Unverified, &user.AwaitingApproval,
+		&user.CreatedAt, &verifiedAt, &codeIssuedAt, &user.ResendCount, &resendWindowAt, &restrictedUntil, &reverifyAskedAt,
+		&user.ReverifyLapsed,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	if teams != "" {
+		user.Teams = strings.Split(teams, teamSeparator)
+		sort.Strings(user.Teams)
 	}
 
-	database := &Database{db: db}
-	if err := database.createTables(); err != nil {
-		return nil, err
+	if verifiedAt.Valid {
+		user.VerifiedAt = &verifiedAt.Time
 	}
 
-	return database, nil
+	user.CodeIssuedAt = user.CreatedAt
+	if codeIssuedAt.Valid {
+		user.CodeIssuedAt = codeIssuedAt.Time
+	}
+
+	if resendWindowAt.Valid {
+		user.ResendWindowAt = &resendWindowAt.Time
+	}
+
+	if restrictedUntil.Valid {
+		user.RestrictedUntil = &restrictedUntil.Time
+	}
+
+	if reverifyAskedAt.Valid {
+		user.ReverifyAskedAt = &reverifyAskedAt.Time
+	}
+
+	return &user, nil
 }
 
-func (d *Database) createTables() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		discord_id TEXT UNIQUE NOT NULL,
-		discord_username TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL,
-		verification_code TEXT UNIQUE NOT NULL,
-		team_role TEXT,
-		verified BOOLEAN DEFAULT FALSE,
-		unverified BOOLEAN DEFAULT FALSE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		verified_at DATETIME
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_discord_id ON users(discord_id);
-	CREATE INDEX IF NOT EXISTS idx_email ON users(email);
-	CREATE INDEX IF NOT EXISTS idx_verification_code ON users(verification_code);
-	`
+// TeamNames lists the user's teams for messages, e.g. "Engineering, Product"
+func (u *User) TeamNames() string {
+	return strings.Join(u.Teams, ", ")
+}
 
-	_, err := d.db.Exec(schema)
-	return err
+// HasTeam reports whether the user is on the named team
+func (u *User) HasTeam(team string) bool {
+	return slices.Contains(u.Teams, team)
 }
 
-func (d *Database) CreateUser(discordID, username, email, verificationCode string) error {
-	query := `
-		INSERT INTO users (discord_id, discord_username, email, verification_code)
-		VALUES (?, ?, ?, ?)
-	`
-	_, err := d.db.Exec(query, discordID, username, email, verificationCode)
-	return err
+// IsPending reports whether the user is still waiting to click their verification link
+func (u *User) IsPending() bool {
+	return !u.Verified && !u.Unverified && !u.AwaitingApproval && !u.ReverifyLapsed
 }
 
-func (d *Database) GetUserByDiscordID(discordID string) (*User, error) {
-	query := `
-		SELECT id, discord_id, discord_username, email, verification_code, 
-		       COALESCE(team_role, ''), verified, COALESCE(unverified, 0), created_at, verified_at
-		FROM users WHERE discord_id = ?
-	`
-	
-	var user User
-	var verifiedAt sql.NullTime
-	
-	err := d.db.QueryRow(query, discordID).Scan(
-		&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email,
-		&user.VerificationCode, &user.TeamRole, &user.Verified, &user.Unverified,
-		&user.CreatedAt, &verifiedAt,
-	)
-	
+// CodeExpired reports whether a pending user's verification code is older than ttl
+func (u *User) CodeExpired(ttl time.Duration) bool {
+	return u.IsPending() && time.Since(u.CodeIssuedAt) > ttl
+}
+
+// NewDatabase opens the database and applies any pending schema migrations
+func NewDatabase(dbPath string) (*Database, error) {
+	database, err := OpenDatabase(dbPath)
 	if err != nil {
 		return nil, err
 	}
-	
-	if verifiedAt.Valid {
-		user.VerifiedAt = &verifiedAt.Time
-	}
-	
-	return &user, nil
-}
 
-func (d *Database) GetUserByEmail(email string) (*User, error) {
-	query := `
-		SELECT id, discord_id, discord_username, email, verification_code,
-		       COALESCE(team_role, ''), verified, COALESCE(unverified, 0), created_at, verified_at
-		FROM users WHERE email = ?
-	`
-
-	var user User
-	var verifiedAt sql.NullTime
+	if err := database.Migrate(); err != nil {
+		database.Close()
+		return nil, err
+	}
 
-	err := d.db.QueryRow(query, email).Scan(
-		&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email,
-		&user.VerificationCode, &user.TeamRole, &user.Verified, &user.Unverified,
-		&user.CreatedAt, &verifiedAt,
-	)
+	return database, nil
+}
 
+// OpenDatabase opens the database without touching its schema
+func OpenDatabase(dbPath string) (*Database, error) {
+	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if verifiedAt.Valid {
-		user.VerifiedAt = &verifiedAt.Time
+	if err := db.Ping(); err != nil {
+		return nil, err
 	}
 
-	return &user, nil
+	return &Database{db: db}, nil
 }
 
-func (d *Database) GetUserByUsername(username string) (*User, error) {
+func (d *Database) CreateUser(discordID, username, email, emailCanonical, verificationCode string) error {
 	query := `
-		SELECT id, discord_id, discord_username, email, verification_code,
-		       COALESCE(team_role, ''), verified, COALESCE(unverified, 0), created_at, verified_at
-		FROM users WHERE discord_username = ?
+		INSERT INTO users (discord_id, discord_username, email, email_canonical, verification_code, code_issued_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`
+	_, err := d.db.Exec(query, discordID, username, email, emailCanonical, verificationCode)
+	return err
+}
 
-	var user User
-	var verifiedAt sql.NullTime
+func (d *Database) GetUserByDiscordID(discordID string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE discord_id = ?`
+	return scanUser(d.db.QueryRow(query, discordID))
+}
 
-	err := d.db.QueryRow(query, username).Scan(
-		&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email,
-		&user.VerificationCode, &user.TeamRole, &user.Verified, &user.Unverified,
-		&user.CreatedAt, &verifiedAt,
-	)
+// GetUserByEmail finds a user by their exact address, falling back to the canonical form
+func (d *Database) GetUserByEmail(email, emailCanonical string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ? OR email_canonical = ? ORDER BY email = ? DESC LIMIT 1`
+	return scanUser(d.db.QueryRow(query, email, emailCanonical, email))
+}
 
+func (d *Database) GetUserByUsername(username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE discord_username = ?`
+	return scanUser(d.db.QueryRow(query, username))
+}
+
+// GetUserByVerificationCode looks up a user by their verification code.
+// If the user is still pending and the code is older than ttl, the user is
+// returned together with ErrVerificationCodeExpired.
+func (d *Database) GetUserByVerificationCode(code string, ttl time.Duration) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE verification_code = ?`
+
+	user, err := scanUser(d.db.QueryRow(query, code))
 	if err != nil {
 		return nil, err
 	}
 
-	if verifiedAt.Valid {
-		user.VerifiedAt = &verifiedAt.Time
+	if user.CodeExpired(ttl) {
+		return user, ErrVerificationCodeExpired
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (d *Database) GetUserByVerificationCode(code string) (*User, error) {
+// resendWindowUpdate issues a new code timestamp and counts the email against the 24 hour resend window
+const resendWindowUpdate = `
+		    code_issued_at = CURRENT_TIMESTAMP,
+		    resend_count = CASE
+		        WHEN resend_window_at IS NULL OR resend_window_at < datetime('now', '-1 day') THEN 1
+		        ELSE COALESCE(resend_count, 0) + 1 END,
+		    resend_window_at = CASE
+		        WHEN resend_window_at IS NULL OR resend_window_at < datetime('now', '-1 day') THEN CURRENT_TIMESTAMP
+		        ELSE resend_window_at END`
+
+// RegenerateVerificationCode replaces a pending user's verification code and
+// counts the resend against their 24 hour resend window
+func (d *Database) RegenerateVerificationCode(discordID, verificationCode string) error {
 	query := `
-		SELECT id, discord_id, discord_username, email, verification_code, 
-		       COALESCE(team_role, ''), verified, created_at, verified_at
-		FROM users WHERE verification_code = ?
+		UPDATE users
+		SET verification_code = ?,` + resendWindowUpdate + `
+		WHERE discord_id = ? AND ` + pendingCondition + `
 	`
-	
-	var user User
-	var verifiedAt sql.NullTime
-	
-	err := d.db.QueryRow(query, code).Scan(
-		&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email,
-		&user.VerificationCode, &user.TeamRole, &user.Verified,
-		&user.CreatedAt, &verifiedAt,
-	)
-	
+	return d.execPendingUpdate(query, verificationCode, discordID)
+}
+
+// UpdatePendingEmail replaces a pending user's email and verification code,
+// invalidating the link sent to the old address
+func (d *Database) UpdatePendingEmail(discordID, email, emailCanonical, verificationCode string) error {
+	query := `
+		UPDATE users
+		SET email = ?, email_canonical = ?, verification_code = ?,` + resendWindowUpdate + `
+		WHERE discord_id = ? AND ` + pendingCondition + `
+	`
+	return d.execPendingUpdate(query, email, emailCanonical, verificationCode, discordID)
+}
+
+// execPendingUpdate runs an update against a pending user and returns sql.ErrNoRows if no pending user matched
+func (d *Database) execPendingUpdate(query string, args ...interface{}) error {
+	result, err := d.db.Exec(query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	if verifiedAt.Valid {
-		user.VerifiedAt = &verifiedAt.Time
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
 	}
-	
-	return &user, nil
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResendsInWindow returns how many resends the user has used in the last 24 hours
+func (u *User) ResendsInWindow() int {
+	if u.ResendWindowAt == nil || time.Since(*u.ResendWindowAt) > 24*time.Hour {
+		return 0
+	}
+	return u.ResendCount
 }
 
-func (d *Database) UpdateUserTeam(discordID, teamRole string) error {
+// VerifyUserWithTeams marks a user verified and replaces their teams
+func (d *Database) VerifyUserWithTeams(discordID string, teams []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE users
-		SET team_role = ?, verified = TRUE, verified_at = CURRENT_TIMESTAMP
+		SET verified = TRUE, verified_at = CURRENT_TIMESTAMP, reverify_requested_at = NULL, reverify_lapsed = FALSE
 		WHERE discord_id = ?
 	`
-	_, err := d.db.Exec(query, teamRole, discordID)
-	return err
+	if _, err := tx.Exec(query, discordID); err != nil {
+		return err
+	}
+	if err := replaceUserTeams(tx, discordID, teams); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetUserTeams replaces all of a user's teams
+func (d *Database) SetUserTeams(discordID string, teams []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceUserTeams(tx, discordID, teams); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceUserTeams(tx *sql.Tx, discordID string, teams []string) error {
+	if _, err := tx.Exec(`DELETE FROM user_teams WHERE discord_id = ?`, discordID); err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO user_teams (discord_id, team) VALUES (?, ?)`, discordID, team); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddUserTeam puts a user on a team, returning false if they were already on it
+func (d *Database) AddUserTeam(discordID, team string) (bool, error) {
+	result, err := d.db.Exec(`INSERT OR IGNORE INTO user_teams (discord_id, team) VALUES (?, ?)`, discordID, team)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveUserTeam takes a user off a team, returning false if they weren't on it
+func (d *Database) RemoveUserTeam(discordID, team string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM user_teams WHERE discord_id = ? AND team = ?`, discordID, team)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
 
 func (d *Database) MarkUserVerified(discordID string) error {
 	query := `
 		UPDATE users
-		SET verified = TRUE, verified_at = CURRENT_TIMESTAMP
+		SET verified = TRUE, verified_at = CURRENT_TIMESTAMP, reverify_requested_at = NULL, reverify_lapsed = FALSE
 		WHERE discord_id = ?
 	`
 	_, err := d.db.Exec(query, discordID)
 	return err
 }
 
-func (d *Database) UnverifyUser(discordID string) error {
+// UnverifyUser restricts a user. A nil until keeps them restricted until a moderator lifts it.
+func (d *Database) UnverifyUser(discordID string, until *time.Time) error {
+	var restrictedUntil interface{}
+	if until != nil {
+		restrictedUntil = until.UTC().Format("2006-01-02 15:04:05")
+	}
 	query := `
 		UPDATE users 
-		SET verified = FALSE, unverified = TRUE
+		SET verified = FALSE, unverified = TRUE, restricted_until = ?
 		WHERE discord_id = ?
 	`
-	_, err := d.db.Exec(query, discordID)
+	_, err := d.db.Exec(query, restrictedUntil, discordID)
 	return err
 }
 
 func (d *Database) ReverifyUser(discordID string) error {
 	query := `
 		UPDATE users 
-		SET verified = TRUE, unverified = FALSE, verified_at = CURRENT_TIMESTAMP
+		SET verified = TRUE, unverified = FALSE, verified_at = CURRENT_TIMESTAMP, restricted_until = NULL, reverify_requested_at = NULL, reverify_lapsed = FALSE
 		WHERE discord_id = ?
 	`
 	_, err := d.db.Exec(query, discordID)
@@ -224,45 +353,144 @@ func (d *Database) ReverifyUser(discordID string) error {
 }
 
 func (d *Database) DeleteUser(discordID string) error {
-	query := `DELETE FROM users WHERE discord_id = ?`
-	_, err := d.db.Exec(query, discordID)
-	return err
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE discord_id = ?`, discordID); err != nil {
+		return err
+	}
+	if err := deleteUserChildRows(tx, discordID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteUserChildRows removes the rows in other tables that belong to a deleted
+// user, so a later registration with the same Discord ID starts clean
+func deleteUserChildRows(tx *sql.Tx, discordID string) error {
+	for _, table := range []string{"user_teams", "team_change_requests", "verification_approvals"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE discord_id = ?`, discordID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d *Database) GetAllUsers() ([]User, error) {
-	query := `
-		SELECT id, discord_id, discord_username, email, verification_code, 
-		       COALESCE(team_role, ''), verified, COALESCE(unverified, 0), created_at, verified_at
-		FROM users ORDER BY created_at DESC
-	`
-	
-	rows, err := d.db.Query(query)
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at DESC`
+	return d.queryUsers(query)
+}
+
+// UserFilter narrows a user list query; zero values match everything
+type UserFilter struct {
+	Status       string     // "pending", "awaiting", "lapsed", "verified" or "restricted"
+	Team         string     // Exact team name; matches users on that team among others
+	Domain       string     // Email domain, e.g. "company.com"
+	JoinedAfter  *time.Time // Joined on or after this day
+	JoinedBefore *time.Time // Joined on or before this day
+}
+
+// ListUsers returns one page of users matching filter, newest first, and the total number of matches
+func (d *Database) ListUsers(filter UserFilter, limit, offset int) ([]User, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	switch filter.Status {
+	case "pending":
+		conditions = append(conditions, pendingCondition)
+	case "awaiting":
+		conditions = append(conditions, "COALESCE(awaiting_approval, 0) = 1")
+	case "lapsed":
+		conditions = append(conditions, "COALESCE(reverify_lapsed, 0) = 1")
+	case "verified":
+		conditions = append(conditions, "verified = TRUE")
+	case "restricted":
+		conditions = append(conditions, "COALESCE(unverified, 0) = 1")
+	}
+	if filter.Team != "" {
+		conditions = append(conditions, "discord_id IN (SELECT discord_id FROM user_teams WHERE team = ?)")
+		args = append(args, filter.Team)
+	}
+	if filter.Domain != "" {
+		conditions = append(conditions, "LOWER(substr(email, instr(email, '@') + 1)) = ?")
+		args = append(args, strings.ToLower(filter.Domain))
+	}
+	if filter.JoinedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.JoinedAfter.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if filter.JoinedBefore != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.JoinedBefore.AddDate(0, 0, 1).UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM users `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users ` + where + ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	users, err := d.queryUsers(query, append(args, limit, offset)...)
+	return users, total, err
+}
+
+// GetExpiredPendingUsers returns pending users whose verification code is older than ttl
+func (d *Database) GetExpiredPendingUsers(ttl time.Duration) ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE ` + pendingCondition + `
+		  AND COALESCE(code_issued_at, created_at) < datetime('now', ?)
+		ORDER BY created_at`
+	return d.queryUsers(query, fmt.Sprintf("-%d seconds", int64(ttl.Seconds())))
+}
+
+// DeletePendingUser removes a user only if they are still pending, so a user
+// who verifies while the janitor is running is never deleted
+func (d *Database) DeletePendingUser(discordID string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM users WHERE discord_id = ? AND ` + pendingCondition
+	result, err := tx.Exec(query, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	if err := deleteUserChildRows(tx, discordID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (d *Database) queryUsers(query string, args ...interface{}) ([]User, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	
+
 	var users []User
 	for rows.Next() {
-		var user User
-		var verifiedAt sql.NullTime
-		
-		err := rows.Scan(
-			&user.ID, &user.DiscordID, &user.DiscordUsername, &user.Email,
-			&user.VerificationCode, &user.TeamRole, &user.Verified, &user.Unverified,
-			&user.CreatedAt, &verifiedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		
-		if verifiedAt.Valid {
-			user.VerifiedAt = &verifiedAt.Time
-		}
-		
-		users = append(users, user)
+		users = append(users, *user)
 	}
-	
+
 	return users, rows.Err()
 }
 
@@ -291,10 +519,10 @@ func (d *Database) DiscordIDExists(discordID string) (bool, error) {
 	return exists, err
 }
 
-// Check if email exists
-func (d *Database) EmailExists(email string) (bool, error) {
+// EmailExists checks whether any user's address has this canonical form
+func (d *Database) EmailExists(emailCanonical string) (bool, error) {
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)`
-	err := d.db.QueryRow(query, email).Scan(&exists)
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email_canonical = ?)`
+	err := d.db.QueryRow(query, emailCanonical).Scan(&exists)
 	return exists, err
 }
\ No newline at end of file
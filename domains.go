@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// approved_domains entries come in three forms:
+//
+//	company.com               exactly company.com
+//	*.company.com             any subdomain of company.com (not company.com itself)
+//	!contractors.company.com  exclusion; also accepts wildcards (!*.contractors.company.com)
+//
+// Exclusions always win, regardless of their order in the list.
+
+// isDomainApproved reports whether domain is allowed by the approved_domains patterns
+func isDomainApproved(domain string, patterns []string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if domain == "" {
+		return false
+	}
+
+	approved := false
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if exclusion, ok := strings.CutPrefix(pattern, "!"); ok {
+			if domainMatches(domain, exclusion) {
+				return false
+			}
+			continue
+		}
+		if domainMatches(domain, pattern) {
+			approved = true
+		}
+	}
+	return approved
+}
+
+// domainMatches matches a single inclusion pattern (without the ! prefix).
+// Internationalized patterns are compared in their punycode form, which is
+// how domains are stored.
+func domainMatches(domain, pattern string) bool {
+	if parent, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(domain, "."+asciiPattern(parent))
+	}
+	return domain == asciiPattern(pattern)
+}
+
+// asciiPattern converts a domain to punycode, leaving it alone if it can't be converted
+func asciiPattern(domain string) string {
+	if ascii, err := toASCIIDomain(domain); err == nil {
+		return ascii
+	}
+	return domain
+}
+
+// isValidDomainPattern reports whether an approved_domains entry is well formed
+func isValidDomainPattern(pattern string) bool {
+	pattern = strings.TrimPrefix(pattern, "!")
+	pattern = strings.TrimPrefix(pattern, "*.")
+	if pattern == "" || strings.HasSuffix(pattern, ".") || strings.ContainsAny(pattern, "@*! \t") {
+		return false
+	}
+	_, err := toASCIIDomain(pattern)
+	return err == nil
+}
+
+// ApprovedDomain is an approved_domains entry added at runtime with /heimdall-domains add
+type ApprovedDomain struct {
+	Domain      string
+	AddedByID   string
+	AddedByName string
+	CreatedAt   time.Time
+}
+
+func (d *Database) GetApprovedDomains() ([]ApprovedDomain, error) {
+	rows, err := d.db.Query(`SELECT domain, added_by_id, added_by_name, created_at FROM approved_domains ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []ApprovedDomain
+	for rows.Next() {
+		var domain ApprovedDomain
+		if err := rows.Scan(&domain.Domain, &domain.AddedByID, &domain.AddedByName, &domain.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// AddApprovedDomain stores a domain pattern, returning false if it was already present
+func (d *Database) AddApprovedDomain(domain, addedByID, addedByName string) (bool, error) {
+	query := `INSERT OR IGNORE INTO approved_domains (domain, added_by_id, added_by_name) VALUES (?, ?, ?)`
+	result, err := d.db.Exec(query, domain, addedByID, addedByName)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveApprovedDomain deletes a domain pattern, returning false if it wasn't present
+func (d *Database) RemoveApprovedDomain(domain string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM approved_domains WHERE domain = ?`, domain)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// approvedDomainPatterns merges approved_domains from config.yaml with the
+// entries moderators have added. If the database can't be read the config
+// list is still used, so verification keeps working.
+func (b *Bot) approvedDomainPatterns() []string {
+	patterns := append([]string{}, b.config().ApprovedDomains...)
+
+	domains, err := b.db.GetApprovedDomains()
+	if err != nil {
+		LogError("Error loading approved domains from database: %v", err)
+		return patterns
+	}
+	for _, domain := range domains {
+		patterns = append(patterns, domain.Domain)
+	}
+	return patterns
+}
+
+// normalizeDomainPattern lowercases a pattern and strips a leading @ that people often paste
+func normalizeDomainPattern(pattern string) string {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	exclusion := strings.HasPrefix(pattern, "!")
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "@")
+	if exclusion {
+		return "!" + pattern
+	}
+	return pattern
+}
+
+// describeDomainPattern renders an approved_domains entry for embeds
+func describeDomainPattern(pattern string) string {
+	if excluded, ok := strings.CutPrefix(pattern, "!"); ok {
+		return fmt.Sprintf("🚫 %s (excluded)", excluded)
+	} else if strings.HasPrefix(pattern, "*.") {
+		return fmt.Sprintf("• %s (any subdomain)", pattern)
+	}
+	return "• " + pattern
+}
+
+func (b *Bot) inConfigDomains(pattern string) bool {
+	for _, domain := range b.config().ApprovedDomains {
+		if normalizeDomainPattern(domain) == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) handleDomains(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	subcommand := i.ApplicationCommandData().Options[0]
+	switch subcommand.Name {
+	case "list":
+		b.handleDomainsList(s, i)
+	case "add":
+		b.handleDomainsAdd(s, i, normalizeDomainPattern(subcommand.Options[0].StringValue()))
+	case "remove":
+		b.handleDomainsRemove(s, i, normalizeDomainPattern(subcommand.Options[0].StringValue()))
+	}
+}
+
+func (b *Bot) handleDomainsList(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var configList strings.Builder
+	for _, domain := range b.config().ApprovedDomains {
+		configList.WriteString(describeDomainPattern(domain) + "\n")
+	}
+	if configList.Len() == 0 {
+		configList.WriteString("None")
+	}
+
+	domains, err := b.db.GetApprovedDomains()
+	if err != nil {
+		LogError("Error getting approved domains: %v", err)
+		b.respondEphemeral(s, i, "❌ Error retrieving approved domains.")
+		return
+	}
+
+	var addedList strings.Builder
+	for _, domain := range domains {
+		addedList.WriteString(fmt.Sprintf("%s\n└ added by %s %s\n", describeDomainPattern(domain.Domain), domain.AddedByName, discordTimestamp(domain.CreatedAt)))
+	}
+	if addedList.Len() == 0 {
+		addedList.WriteString("None. Use `/heimdall-domains add` to approve a domain.")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "📧 Approved Email Domains",
+		Color: 0x667eea,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "From config.yaml", Value: truncate(configList.String(), 1024)},
+			{Name: "Added by Moderators", Value: truncate(addedList.String(), 1024)},
+		},
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func (b *Bot) handleDomainsAdd(s *discordgo.Session, i *discordgo.InteractionCreate, pattern string) {
+	if !isValidDomainPattern(pattern) {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ `%s` is not a valid domain. Use `company.com`, `*.company.com` or `!contractors.company.com`.", pattern))
+		return
+	}
+
+	if b.inConfigDomains(pattern) {
+		b.respondEphemeral(s, i, fmt.Sprintf("ℹ️ `%s` is already in config.yaml.", pattern))
+		return
+	}
+
+	added, err := b.db.AddApprovedDomain(pattern, i.Member.User.ID, i.Member.User.Username)
+	if err != nil {
+		LogError("Error adding approved domain %s: %v", pattern, err)
+		b.respondEphemeral(s, i, "❌ Error adding domain.")
+		return
+	}
+	if !added {
+		b.respondEphemeral(s, i, fmt.Sprintf("ℹ️ `%s` is already approved.", pattern))
+		return
+	}
+
+	LogSuccess("Moderator %s added approved domain %s", i.Member.User.Username, pattern)
+	b.audit(i, AuditActionDomainAdd, "", pattern, "", "", describeDomainPattern(pattern))
+
+	message := fmt.Sprintf("✅ Added `%s` to the approved domains.", pattern)
+	if strings.HasPrefix(pattern, "!") {
+		message = fmt.Sprintf("✅ Excluded `%s` from the approved domains.", strings.TrimPrefix(pattern, "!"))
+	}
+	b.respondEphemeral(s, i, message)
+}
+
+func (b *Bot) handleDomainsRemove(s *discordgo.Session, i *discordgo.InteractionCreate, pattern string) {
+	removed, err := b.db.RemoveApprovedDomain(pattern)
+	if err != nil {
+		LogError("Error removing approved domain %s: %v", pattern, err)
+		b.respondEphemeral(s, i, "❌ Error removing domain.")
+		return
+	}
+	if !removed {
+		if b.inConfigDomains(pattern) {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ `%s` is defined in config.yaml. Edit the file to remove it.", pattern))
+		} else {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ `%s` is not an approved domain.", pattern))
+		}
+		return
+	}
+
+	LogSuccess("Moderator %s removed approved domain %s", i.Member.User.Username, pattern)
+	b.audit(i, AuditActionDomainRemove, "", pattern, "", describeDomainPattern(pattern), "")
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ Removed `%s` from the approved domains.", pattern))
+}
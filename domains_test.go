@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestIsDomainApproved(t *testing.T) {
+	patterns := []string{
+		"company.com",
+		"*.company.com",
+		"!contractors.company.com",
+		"!*.contractors.company.com",
+		" Partner.COM ",
+		"bücher.de",
+	}
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"company.com", true},
+		{"COMPANY.com", true},
+		{"company.com.", true},
+		{"eng.company.com", true},
+		{"deep.eng.company.com", true},
+		{"contractors.company.com", false},
+		{"eu.contractors.company.com", false},
+		{"notcompany.com", false},
+		{"company.com.evil.com", false},
+		{"partner.com", true},
+		{"sub.partner.com", false},
+		{"xn--bcher-kva.de", true},
+		{"other.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := isDomainApproved(tt.domain, patterns); got != tt.want {
+				t.Errorf("isDomainApproved(%q) = %t, want %t", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDomainApprovedWildcardOnly(t *testing.T) {
+	patterns := []string{"*.company.com"}
+
+	if isDomainApproved("company.com", patterns) {
+		t.Error("*.company.com should not approve company.com itself")
+	}
+	if !isDomainApproved("eng.company.com", patterns) {
+		t.Error("*.company.com should approve eng.company.com")
+	}
+}
+
+func TestIsDomainApprovedExclusionOrder(t *testing.T) {
+	// Exclusions win wherever they appear in the list
+	patterns := []string{"!contractors.company.com", "*.company.com"}
+
+	if isDomainApproved("contractors.company.com", patterns) {
+		t.Error("exclusion listed first should still win")
+	}
+}
+
+func TestIsValidDomainPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"company.com", true},
+		{"*.company.com", true},
+		{"!contractors.company.com", true},
+		{"!*.contractors.company.com", true},
+		{"bücher.de", true},
+		{"xn--bcher-kva.de", true},
+		{"", false},
+		{"*.", false},
+		{"!", false},
+		{"company.com.", false},
+		{"company..com", false},
+		{".company.com", false},
+		{"alice@company.com", false},
+		{"*company.com", false},
+		{"eng.*.company.com", false},
+		{"!!company.com", false},
+		{"com pany.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := isValidDomainPattern(tt.pattern); got != tt.want {
+				t.Errorf("isValidDomainPattern(%q) = %t, want %t", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,20 +3,26 @@ package main
 import (
 	"fmt"
 	"net/smtp"
+	"time"
 )
 
 type EmailService struct {
-	config *Config
+	configs *ConfigStore
 }
 
-func NewEmailService(config *Config) *EmailService {
-	return &EmailService{config: config}
+func NewEmailService(configs *ConfigStore) *EmailService {
+	return &EmailService{configs: configs}
+}
+
+func (e *EmailService) config() *Config {
+	return e.configs.Get()
 }
 
 func (e *EmailService) SendVerificationEmail(toEmail, verificationCode, username string) error {
-	verificationURL := fmt.Sprintf("%s/verify?code=%s", e.config.Server.BaseURL, verificationCode)
+	verificationURL := fmt.Sprintf("%s/verify?code=%s", e.config().Server.BaseURL, verificationCode)
 
 	subject := "Verify Your Discord Account"
+	expiryHours := int(e.config().CodeTTL().Hours())
 
 	// Plain text version
 	plainBody := fmt.Sprintf(`Hello %s,
@@ -25,6 +31,8 @@ Welcome to the server! Please verify your email address by clicking the link bel
 
 %s
 
+This link expires in %d hours.
+
 This link will allow you to:
 1. Confirm your email address
 2. Select your team role
@@ -34,7 +42,7 @@ Once you've completed these steps, you'll be granted access to the server.
 If you didn't request this verification, please ignore this email.
 
 Best regards,
-The Heimdall Bot Team`, username, verificationURL)
+The Heimdall Bot Team`, username, verificationURL, expiryHours)
 
 	// HTML version with clickable link
 	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
@@ -68,6 +76,8 @@ The Heimdall Bot Team`, username, verificationURL)
             <p style="text-align: center; color: #666; font-size: 14px;">Or copy and paste this link into your browser:<br>
             <code style="background: #e0e0e0; padding: 5px 10px; border-radius: 3px; word-break: break-all;">%s</code></p>
             
+            <p style="text-align: center; color: #666; font-size: 14px;">This link expires in %d hours.</p>
+            
             <div class="steps">
                 <p>Once you've completed the steps on the link above, you'll be granted access to the server.</p>
             </div>
@@ -79,8 +89,13 @@ The Heimdall Bot Team`, username, verificationURL)
         </div>
     </div>
 </body>
-</html>`, username, verificationURL, verificationURL)
+</html>`, username, verificationURL, verificationURL, expiryHours)
 
+	return e.send(toEmail, subject, plainBody, htmlBody)
+}
+
+// send delivers a multipart message with both plain text and HTML bodies
+func (e *EmailService) send(toEmail, subject, plainBody, htmlBody string) error {
 	// Create multipart message with both plain text and HTML
 	message := fmt.Sprintf("From: %s <%s>\r\n"+
 		"To: %s\r\n"+
@@ -97,24 +112,92 @@ The Heimdall Bot Team`, username, verificationURL)
 		"\r\n"+
 		"%s\r\n"+
 		"--boundary123--\r\n",
-		e.config.Email.FromName, e.config.Email.FromAddress,
+		e.config().Email.FromName, e.config().Email.FromAddress,
 		toEmail,
 		subject,
 		plainBody,
 		htmlBody)
 
 	auth := smtp.PlainAuth("",
-		e.config.Email.SMTPUsername,
-		e.config.Email.SMTPPassword,
-		e.config.Email.SMTPHost,
+		e.config().Email.SMTPUsername,
+		e.config().Email.SMTPPassword,
+		e.config().Email.SMTPHost,
 	)
 
-	addr := fmt.Sprintf("%s:%d", e.config.Email.SMTPHost, e.config.Email.SMTPPort)
+	addr := fmt.Sprintf("%s:%d", e.config().Email.SMTPHost, e.config().Email.SMTPPort)
 
-	err := smtp.SendMail(addr, auth, e.config.Email.FromAddress, []string{toEmail}, []byte(message))
+	err := smtp.SendMail(addr, auth, e.config().Email.FromAddress, []string{toEmail}, []byte(message))
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
 	return nil
 }
+
+// SendReverificationEmail asks a member to confirm they still have their work
+// email, before deadline or, with a nil deadline, to restore paused access
+func (e *EmailService) SendReverificationEmail(toEmail, verificationCode, username string, deadline *time.Time) error {
+	verificationURL := fmt.Sprintf("%s/verify?code=%s", e.config().Server.BaseURL, verificationCode)
+
+	subject := "Confirm Your Discord Access"
+
+	// Members whose access is already paused have no deadline
+	plainNotice := "Your server access is paused until you confirm."
+	htmlNotice := plainNotice
+	if deadline != nil {
+		deadlineText := deadline.UTC().Format("Monday, January 2 at 15:04 UTC")
+		plainNotice = fmt.Sprintf("If you don't confirm by %s, your server access will be paused until you verify again.", deadlineText)
+		htmlNotice = fmt.Sprintf("If you don't confirm by <strong>%s</strong>, your server access will be paused until you verify again.", deadlineText)
+	}
+
+	plainBody := fmt.Sprintf(`Hello %s,
+
+It's time to confirm that you still have access to this work email address. Please click the link below:
+
+%s
+
+%s
+
+Best regards,
+The Heimdall Bot Team`, username, verificationURL, plainNotice)
+
+	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white; padding: 20px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 8px 8px; }
+        .button { display: inline-block; padding: 15px 30px; background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white !important; text-decoration: none; border-radius: 5px; font-weight: bold; margin: 20px 0; }
+        .footer { text-align: center; color: #666; font-size: 12px; margin-top: 20px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🛡️ Heimdall Verification</h1>
+        </div>
+        <div class="content">
+            <p>Hello <strong>%s</strong>,</p>
+            <p>It's time to confirm that you still have access to this work email address.</p>
+
+            <center>
+                <a href="%s" class="button">Confirm My Email</a>
+            </center>
+
+            <p style="text-align: center; color: #666; font-size: 14px;">Or copy and paste this link into your browser:<br>
+            <code style="background: #e0e0e0; padding: 5px 10px; border-radius: 3px; word-break: break-all;">%s</code></p>
+
+            <p style="text-align: center; color: #666; font-size: 14px;">%s</p>
+        </div>
+        <div class="footer">
+            <p>Best regards,<br>The Heimdall Bot Team</p>
+        </div>
+    </div>
+</body>
+</html>`, username, verificationURL, verificationURL, htmlNotice)
+
+	return e.send(toEmail, subject, plainBody, htmlBody)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// EmailRuleType says whether an address is let in or kept out regardless of its domain
+type EmailRuleType string
+
+const (
+	EmailRuleAllow EmailRuleType = "allow"
+	EmailRuleBlock EmailRuleType = "block"
+)
+
+// EmailRule is an allowlist or blocklist entry for a single address
+type EmailRule struct {
+	Email       string
+	Rule        EmailRuleType
+	Reason      string
+	AddedByID   string
+	AddedByName string
+	CreatedAt   time.Time
+}
+
+// emailAccess is the outcome of checking an address against the blocklist,
+// the allowlist and the approved domains, in that order
+type emailAccess int
+
+const (
+	emailNotApproved emailAccess = iota
+	emailApproved
+	emailBlocked
+	emailCheckFailed // The rules couldn't be read; callers should ask the user to try again
+)
+
+// GetEmailRule returns the rule for an address, or sql.ErrNoRows if there is none
+func (d *Database) GetEmailRule(email string) (*EmailRule, error) {
+	query := `SELECT email, rule, COALESCE(reason, ''), added_by_id, added_by_name, created_at FROM email_rules WHERE email = ?`
+	var rule EmailRule
+	var ruleType string
+	err := d.db.QueryRow(query, email).Scan(&rule.Email, &ruleType, &rule.Reason, &rule.AddedByID, &rule.AddedByName, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	rule.Rule = EmailRuleType(ruleType)
+	return &rule, nil
+}
+
+func (d *Database) GetEmailRules(ruleType EmailRuleType) ([]EmailRule, error) {
+	query := `SELECT email, rule, COALESCE(reason, ''), added_by_id, added_by_name, created_at FROM email_rules WHERE rule = ? ORDER BY email`
+	rows, err := d.db.Query(query, string(ruleType))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []EmailRule
+	for rows.Next() {
+		var rule EmailRule
+		var ruleTypeValue string
+		if err := rows.Scan(&rule.Email, &ruleTypeValue, &rule.Reason, &rule.AddedByID, &rule.AddedByName, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		rule.Rule = EmailRuleType(ruleTypeValue)
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SetEmailRule adds an address to a list, moving it if it was on the other one
+func (d *Database) SetEmailRule(rule EmailRule) error {
+	query := `
+		INSERT INTO email_rules (email, rule, reason, added_by_id, added_by_name)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(email) DO UPDATE SET
+			rule = excluded.rule,
+			reason = excluded.reason,
+			added_by_id = excluded.added_by_id,
+			added_by_name = excluded.added_by_name,
+			created_at = CURRENT_TIMESTAMP
+	`
+	_, err := d.db.Exec(query, rule.Email, string(rule.Rule), rule.Reason, rule.AddedByID, rule.AddedByName)
+	return err
+}
+
+// RemoveEmailRule deletes an address from a list, returning false if it wasn't on it
+func (d *Database) RemoveEmailRule(email string, ruleType EmailRuleType) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM email_rules WHERE email = ? AND rule = ?`, email, string(ruleType))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// checkEmailAccess decides whether an address may be used for verification.
+// A blocklisted address is always refused and an allowlisted one always
+// accepted; everything else depends on the approved domains. Rules match the
+// address as typed or its canonical form, so plus tags can't dodge a block.
+// If the rules can't be read the address is refused with emailCheckFailed,
+// so a database error never lets a blocked address through.
+func (b *Bot) checkEmailAccess(email string) emailAccess {
+	allowed := false
+	for _, candidate := range []string{email, b.config().CanonicalEmail(email)} {
+		rule, err := b.db.GetEmailRule(candidate)
+		if err != nil && err != sql.ErrNoRows {
+			LogError("Error checking email rules for %s: %v", candidate, err)
+			return emailCheckFailed
+		}
+		if rule != nil && rule.Rule == EmailRuleBlock {
+			return emailBlocked
+		}
+		if rule != nil && rule.Rule == EmailRuleAllow {
+			allowed = true
+		}
+	}
+	if allowed {
+		return emailApproved
+	}
+
+	if b.isApprovedDomain(email) {
+		return emailApproved
+	}
+	return emailNotApproved
+}
+
+// emailRuleCommandOptions builds the add/remove/list subcommands shared by /heimdall-allow and /heimdall-block
+func emailRuleCommandOptions(verb string) []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "add",
+			Description: fmt.Sprintf("%s an email address", verb),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "email",
+					Description: "Email address",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "reason",
+					Description: "Why this address is listed",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "remove",
+			Description: "Remove an email address from the list",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "email",
+					Description: "Email address",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "Show every address on the list",
+		},
+	}
+}
+
+func (b *Bot) handleEmailRule(s *discordgo.Session, i *discordgo.InteractionCreate, ruleType EmailRuleType) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	subcommand := i.ApplicationCommandData().Options[0]
+	var email, reason string
+	for _, opt := range subcommand.Options {
+		switch opt.Name {
+		case "email":
+			email = strings.TrimSpace(strings.ToLower(opt.StringValue()))
+			if normalized, err := normalizeEmail(email); err == nil {
+				email = normalized
+			}
+		case "reason":
+			reason = opt.StringValue()
+		}
+	}
+
+	switch subcommand.Name {
+	case "add":
+		b.handleEmailRuleAdd(s, i, ruleType, email, reason)
+	case "remove":
+		b.handleEmailRuleRemove(s, i, ruleType, email)
+	case "list":
+		b.handleEmailRuleList(s, i, ruleType)
+	}
+}
+
+func (b *Bot) handleEmailRuleAdd(s *discordgo.Session, i *discordgo.InteractionCreate, ruleType EmailRuleType, email, reason string) {
+	if !isValidEmail(email) {
+		b.respondEphemeral(s, i, "❌ Invalid email format.")
+		return
+	}
+
+	existing, err := b.db.GetEmailRule(email)
+	if err != nil && err != sql.ErrNoRows {
+		LogError("Error checking email rules for %s: %v", email, err)
+		b.respondEphemeral(s, i, "❌ Error updating the list.")
+		return
+	}
+	if existing != nil && existing.Rule == ruleType {
+		b.respondEphemeral(s, i, fmt.Sprintf("ℹ️ `%s` is already on the %slist.", email, ruleType))
+		return
+	}
+
+	rule := EmailRule{
+		Email:       email,
+		Rule:        ruleType,
+		Reason:      reason,
+		AddedByID:   i.Member.User.ID,
+		AddedByName: i.Member.User.Username,
+	}
+	if err := b.db.SetEmailRule(rule); err != nil {
+		LogError("Error adding %s to the %slist: %v", email, ruleType, err)
+		b.respondEphemeral(s, i, "❌ Error updating the list.")
+		return
+	}
+
+	before := "none"
+	if existing != nil {
+		before = string(existing.Rule) + "list"
+	}
+	action := AuditActionAllowlistAdd
+	if ruleType == EmailRuleBlock {
+		action = AuditActionBlocklistAdd
+	}
+	b.audit(i, action, "", email, reason, before, string(ruleType)+"list")
+	LogSuccess("Moderator %s added %s to the %slist", i.Member.User.Username, email, ruleType)
+
+	message := fmt.Sprintf("✅ Added `%s` to the %slist.", email, ruleType)
+	if existing != nil {
+		message = fmt.Sprintf("✅ Moved `%s` from the %slist to the %slist.", email, existing.Rule, ruleType)
+	}
+	if ruleType == EmailRuleBlock {
+		message += " Users already verified with this address keep their access; use `/heimdall-restrict` to remove it."
+	}
+	b.respondEphemeral(s, i, message)
+}
+
+func (b *Bot) handleEmailRuleRemove(s *discordgo.Session, i *discordgo.InteractionCreate, ruleType EmailRuleType, email string) {
+	removed, err := b.db.RemoveEmailRule(email, ruleType)
+	if err != nil {
+		LogError("Error removing %s from the %slist: %v", email, ruleType, err)
+		b.respondEphemeral(s, i, "❌ Error updating the list.")
+		return
+	}
+	if !removed {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ `%s` is not on the %slist.", email, ruleType))
+		return
+	}
+
+	action := AuditActionAllowlistRemove
+	if ruleType == EmailRuleBlock {
+		action = AuditActionBlocklistRemove
+	}
+	b.audit(i, action, "", email, "", string(ruleType)+"list", "none")
+	LogSuccess("Moderator %s removed %s from the %slist", i.Member.User.Username, email, ruleType)
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ Removed `%s` from the %slist.", email, ruleType))
+}
+
+func (b *Bot) handleEmailRuleList(s *discordgo.Session, i *discordgo.InteractionCreate, ruleType EmailRuleType) {
+	rules, err := b.db.GetEmailRules(ruleType)
+	if err != nil {
+		LogError("Error getting %slist: %v", ruleType, err)
+		b.respondEphemeral(s, i, "❌ Error retrieving the list.")
+		return
+	}
+
+	title := "✅ Email Allowlist"
+	if ruleType == EmailRuleBlock {
+		title = "🚫 Email Blocklist"
+	}
+
+	var description strings.Builder
+	if len(rules) == 0 {
+		description.WriteString(fmt.Sprintf("No addresses. Use `/heimdall-%s add` to add one.", ruleType))
+	}
+	for _, rule := range rules {
+		line := fmt.Sprintf("**%s**\n└ added by %s %s\n", rule.Email, rule.AddedByName, discordTimestamp(rule.CreatedAt))
+		if rule.Reason != "" {
+			line += "└ " + truncate(rule.Reason, 100) + "\n"
+		}
+		if description.Len()+len(line) > 3900 {
+			description.WriteString(fmt.Sprintf("…and more (%d total)", len(rules)))
+			break
+		}
+		description.WriteString(line)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description.String(),
+		Color:       0x667eea,
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
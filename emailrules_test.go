@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestCheckEmailAccess(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	rules := []EmailRule{
+		{Email: "former@company.com", Rule: EmailRuleBlock},
+		{Email: "contractor@gmail.com", Rule: EmailRuleAllow},
+	}
+	for _, rule := range rules {
+		rule.AddedByID, rule.AddedByName = "1", "mod"
+		if err := db.SetEmailRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := validConfig()
+	config.EmailNormalization.StripPlusTags = true
+	bot := &Bot{configs: NewConfigStore("", config), db: db}
+
+	tests := []struct {
+		email string
+		want  emailAccess
+	}{
+		{"alice@company.com", emailApproved},
+		{"former@company.com", emailBlocked},
+		{"former+again@company.com", emailBlocked},
+		{"contractor@gmail.com", emailApproved},
+		{"someone@gmail.com", emailNotApproved},
+	}
+	for _, tt := range tests {
+		if got := bot.checkEmailAccess(tt.email); got != tt.want {
+			t.Errorf("checkEmailAccess(%q) = %d, want %d", tt.email, got, tt.want)
+		}
+	}
+
+	// A failed lookup must not fall through to the approved domains
+	db.Close()
+	if got := bot.checkEmailAccess("former@company.com"); got != emailCheckFailed {
+		t.Errorf("checkEmailAccess with the database unavailable = %d, want emailCheckFailed", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts every environment variable that overrides a config setting
+const envPrefix = "HEIMDALL_"
+
+// applyEnvOverrides replaces config values with HEIMDALL_* environment variables.
+// Variable names follow the YAML path, e.g. email.smtp_password is
+// HEIMDALL_EMAIL_SMTP_PASSWORD. Each variable also has a _FILE variant that
+// names a file to read the value from (Docker and Kubernetes secrets).
+//
+// Lists are comma separated (HEIMDALL_APPROVED_DOMAINS=a.com,b.com) and maps
+// are comma separated key=value pairs (HEIMDALL_TEAMS=Engineering=123,Sales=456).
+// Maps of sections such as domain_settings take a JSON object using the YAML
+// field names (HEIMDALL_DOMAIN_SETTINGS='{"company.com":{"locked_team":"Sales"}}').
+func applyEnvOverrides(config *Config) error {
+	return applyEnvToStruct(reflect.ValueOf(config).Elem(), strings.TrimSuffix(envPrefix, "_"))
+}
+
+func applyEnvToStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnvToStruct(v.Field(n), name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok, err := lookupEnv(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(v.Field(n), value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// lookupEnv returns the value of name, or the contents of the file named by
+// name_FILE. Setting both is an error since it's unclear which should win.
+func lookupEnv(name string) (string, bool, error) {
+	value, hasValue := os.LookupEnv(name)
+	path, hasFile := os.LookupEnv(name + "_FILE")
+
+	switch {
+	case hasValue && hasFile:
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", name, name)
+	case hasFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		// Secret files usually end with a newline that isn't part of the value
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	default:
+		return value, hasValue, nil
+	}
+}
+
+func setFromEnv(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(n))
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+
+	case reflect.Slice:
+		list := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, item := range splitEnvList(value) {
+			list = reflect.Append(list, reflect.ValueOf(item))
+		}
+		field.Set(list)
+
+	case reflect.Map:
+		if field.Type().Elem().Kind() == reflect.Struct {
+			// JSON is valid YAML, so this accepts the same field names as config.yaml
+			m := reflect.New(field.Type())
+			if err := yaml.Unmarshal([]byte(value), m.Interface()); err != nil {
+				return fmt.Errorf("not a valid JSON object: %w", err)
+			}
+			field.Set(m.Elem())
+			return nil
+		}
+		m := reflect.MakeMap(field.Type())
+		for _, pair := range splitEnvList(value) {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			entry := reflect.New(field.Type().Elem()).Elem()
+			if err := setFromEnv(entry, strings.TrimSpace(val)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), entry)
+		}
+		field.Set(m)
+
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("HEIMDALL_DISCORD_TOKEN", "env-token")
+	t.Setenv("HEIMDALL_SERVER_PORT", " 9090 ")
+	t.Setenv("HEIMDALL_ROLE_SYNC_ENABLED", "true")
+	t.Setenv("HEIMDALL_APPROVED_DOMAINS", "company.com, ,*.company.co.uk")
+	t.Setenv("HEIMDALL_TEAMS", "Engineering=123, Sales = 456")
+	t.Setenv("HEIMDALL_DOMAIN_SETTINGS", `{"partner.com": {"locked_team": "Sales", "extra_roles": ["789"]}}`)
+
+	config := &Config{}
+	config.Discord.Token = "file-token"
+	config.Discord.GuildID = "guild"
+	if err := applyEnvOverrides(config); err != nil {
+		t.Fatalf("applyEnvOverrides returned error: %v", err)
+	}
+
+	if config.Discord.Token != "env-token" {
+		t.Errorf("Discord.Token = %q, want env-token", config.Discord.Token)
+	}
+	if config.Discord.GuildID != "guild" {
+		t.Errorf("Discord.GuildID = %q, want the value from the file to be kept", config.Discord.GuildID)
+	}
+	if config.Server.Port != 9090 {
+		t.Errorf("Server.Port = %d, want 9090", config.Server.Port)
+	}
+	if !config.RoleSync.Enabled {
+		t.Error("RoleSync.Enabled = false, want true")
+	}
+	if want := []string{"company.com", "*.company.co.uk"}; !slices.Equal(config.ApprovedDomains, want) {
+		t.Errorf("ApprovedDomains = %q, want %q", config.ApprovedDomains, want)
+	}
+	if len(config.Teams) != 2 || config.Teams["Engineering"] != "123" || config.Teams["Sales"] != "456" {
+		t.Errorf("Teams = %v, want Engineering=123 and Sales=456", config.Teams)
+	}
+	settings, ok := config.DomainSettings["partner.com"]
+	if !ok || settings.LockedTeam != "Sales" || !slices.Equal(settings.ExtraRoles, []string{"789"}) {
+		t.Errorf("DomainSettings = %+v, want partner.com locked to Sales with extra role 789", config.DomainSettings)
+	}
+}
+
+func TestApplyEnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HEIMDALL_DISCORD_TOKEN_FILE", path)
+
+	config := &Config{}
+	if err := applyEnvOverrides(config); err != nil {
+		t.Fatalf("applyEnvOverrides returned error: %v", err)
+	}
+	if config.Discord.Token != "secret-token" {
+		t.Errorf("Discord.Token = %q, want secret-token without the trailing newline", config.Discord.Token)
+	}
+}
+
+func TestApplyEnvOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"not a number", map[string]string{"HEIMDALL_SERVER_PORT": "eighty"}},
+		{"not a bool", map[string]string{"HEIMDALL_ROLE_SYNC_ENABLED": "sometimes"}},
+		{"map entry without =", map[string]string{"HEIMDALL_TEAMS": "Engineering"}},
+		{"invalid domain settings JSON", map[string]string{"HEIMDALL_DOMAIN_SETTINGS": `{"partner.com": `}},
+		{"value and file both set", map[string]string{"HEIMDALL_DISCORD_TOKEN": "a", "HEIMDALL_DISCORD_TOKEN_FILE": "/dev/null"}},
+		{"missing file", map[string]string{"HEIMDALL_DISCORD_TOKEN_FILE": "/nonexistent/heimdall-token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if err := applyEnvOverrides(&Config{}); err == nil {
+				t.Error("applyEnvOverrides returned no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// EventType identifies a verification or moderation event published on the EventBus.
+// The string values double as the keys for discord.audit_events in config.yaml.
+type EventType string
+
+const (
+	EventVerification EventType = "verification"
+	EventTeamChange   EventType = "team_change"
+	EventRestriction  EventType = "restriction"
+	EventUnrestrict   EventType = "unrestriction"
+	EventReset        EventType = "reset"
+	EventPurge        EventType = "purge"
+
+	// EventRoleSync is only used as an audit_events toggle for scheduled role sync reports
+	EventRoleSync EventType = "role_sync"
+)
+
+// Event describes something that happened to a user. ActorID is empty when
+// the user acted on their own behalf (e.g. completing web verification).
+type Event struct {
+	Type         EventType
+	UserID       string
+	Username     string
+	ActorID      string
+	ActorName    string
+	Email        string
+	Team         string
+	PreviousTeam string
+	Reason       string
+	Until        *time.Time // When a time-limited restriction ends
+	Time         time.Time
+}
+
+// EventBus fans events out to subscribers on a background goroutine so that
+// publishers (interaction handlers, web requests) never wait on slow consumers
+type EventBus struct {
+	events      chan Event
+	subscribers []func(Event)
+	stopped     bool
+	mu          sync.RWMutex
+	done        chan struct{}
+}
+
+func NewEventBus(buffer int) *EventBus {
+	return &EventBus{
+		events: make(chan Event, buffer),
+		done:   make(chan struct{}),
+	}
+}
+
+// Subscribe registers a handler that is called for every published event
+func (eb *EventBus) Subscribe(handler func(Event)) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.subscribers = append(eb.subscribers, handler)
+}
+
+// Publish queues an event without blocking. If the queue is full the event
+// is dropped and a warning logged rather than stalling the caller.
+func (eb *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	if eb.stopped {
+		return
+	}
+
+	select {
+	case eb.events <- event:
+	default:
+		LogWarn("Event queue full, dropping %s event for %s", event.Type, event.Username)
+	}
+}
+
+// Start dispatches queued events to subscribers until Stop is called
+func (eb *EventBus) Start() {
+	go func() {
+		defer close(eb.done)
+		for event := range eb.events {
+			eb.mu.RLock()
+			subscribers := eb.subscribers
+			eb.mu.RUnlock()
+
+			for _, handler := range subscribers {
+				handler(event)
+			}
+		}
+	}()
+}
+
+// Stop delivers any queued events and then shuts the dispatcher down
+func (eb *EventBus) Stop() {
+	eb.mu.Lock()
+	eb.stopped = true
+	close(eb.events)
+	eb.mu.Unlock()
+
+	<-eb.done
+}
+
+// postAuditEvent mirrors an event to the configured audit channel
+func (b *Bot) postAuditEvent(event Event) {
+	channelID := b.config().Discord.AuditChannelID
+	if channelID == "" || !b.config().AuditEventEnabled(event.Type) {
+		return
+	}
+
+	if _, err := b.session.ChannelMessageSendEmbed(channelID, auditEventEmbed(event)); err != nil {
+		LogError("Error posting %s event for %s to audit channel: %v", event.Type, event.Username, err)
+	}
+}
+
+func auditEventEmbed(event Event) *discordgo.MessageEmbed {
+	title, color := "📋 Event", 0x667eea
+	switch event.Type {
+	case EventVerification:
+		title, color = "✅ User Verified", 0x2ecc71
+	case EventTeamChange:
+		title, color = "📝 Team Changed", 0x3498db
+	case EventRestriction:
+		title, color = "⚠️ User Restricted", 0xe67e22
+	case EventUnrestrict:
+		title, color = "🔓 Restriction Removed", 0x2ecc71
+	case EventReset:
+		title, color = "🔄 Verification Reset", 0x95a5a6
+	case EventPurge:
+		title, color = "🗑️ User Data Purged", 0xe74c3c
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "User",
+			Value:  fmt.Sprintf("<@%s> (%s)", event.UserID, event.Username),
+			Inline: true,
+		},
+	}
+
+	if event.ActorID != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Moderator",
+			Value:  fmt.Sprintf("<@%s> (%s)", event.ActorID, event.ActorName),
+			Inline: true,
+		})
+	}
+	if event.Email != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Email", Value: event.Email, Inline: true})
+	}
+	if event.PreviousTeam != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Previous Team", Value: event.PreviousTeam, Inline: true})
+	}
+	if event.Team != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Team", Value: event.Team, Inline: true})
+	}
+	if event.Until != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Ends", Value: discordTimestamp(*event.Until), Inline: true})
+	}
+	if event.Reason != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Reason", Value: truncate(event.Reason, 1024)})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:     title,
+		Color:     color,
+		Fields:    fields,
+		Timestamp: event.Time.UTC().Format(time.RFC3339),
+	}
+}
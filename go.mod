@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/bwmarrin/discordgo v0.28.1
 	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/net v0.30.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -12,4 +13,5 @@ require (
 	github.com/gorilla/websocket v1.5.3 // indirect
 	golang.org/x/crypto v0.28.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
 )
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"time"
+)
+
+// Janitor periodically removes pending users whose verification code has
+// expired, freeing their email and Discord ID so they can start over
+type Janitor struct {
+	configs *ConfigStore
+	db      *Database
+	bot     *Bot
+	reset   chan struct{} // Signalled when cleanup_interval_minutes changes
+	stop    chan struct{}
+}
+
+func NewJanitor(configs *ConfigStore, db *Database, bot *Bot) *Janitor {
+	return &Janitor{
+		configs: configs,
+		db:      db,
+		bot:     bot,
+		reset:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs the cleanup loop in the background until Stop is called
+func (j *Janitor) Start() {
+	go func() {
+		// Run once at startup so stale rows from downtime are cleared immediately
+		j.purgeExpired()
+
+		timer := time.NewTimer(j.config().CleanupInterval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				j.purgeExpired()
+				timer.Reset(j.config().CleanupInterval())
+			case <-j.reset:
+				// Restart the wait so a new interval applies now, not after the old one runs out
+				timer.Reset(j.config().CleanupInterval())
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (j *Janitor) config() *Config {
+	return j.configs.Get()
+}
+
+// onConfigReload restarts the cleanup wait when cleanup_interval_minutes changes
+func (j *Janitor) onConfigReload(old, new *Config) {
+	if old.CleanupInterval() == new.CleanupInterval() {
+		return
+	}
+	LogInfo("Cleanup interval changed to %s", new.CleanupInterval())
+	select {
+	case j.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (j *Janitor) Stop() {
+	close(j.stop)
+}
+
+func (j *Janitor) purgeExpired() {
+	users, err := j.db.GetExpiredPendingUsers(j.config().CodeTTL())
+	if err != nil {
+		LogError("Error finding expired pending users: %v", err)
+		return
+	}
+
+	if len(users) == 0 {
+		LogDebug("Janitor: no expired pending users")
+		return
+	}
+
+	for _, user := range users {
+		deleted, err := j.db.DeletePendingUser(user.DiscordID)
+		if err != nil {
+			LogError("Error purging expired pending user %s: %v", user.DiscordUsername, err)
+			continue
+		}
+		if !deleted {
+			// User verified or was restricted between the query and the delete
+			continue
+		}
+
+		LogInfo("Purged expired pending user %s (email: %s)", user.DiscordUsername, user.Email)
+		j.bot.SendDM(user.DiscordID, "⌛ Your verification link has expired. Please reply with your work email address to start the verification process again.")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	listPageSize = 10
+
+	// listCustomIDPrefix starts the custom ID of the Prev/Next buttons; the
+	// rest of the ID is the encoded filter and target page
+	listCustomIDPrefix = "heimdall-list?"
+
+	// Discord rejects component custom IDs longer than this
+	maxCustomIDLength = 100
+)
+
+func (b *Bot) handleList(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	var filter UserFilter
+	page := 1
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "status":
+			filter.Status = opt.StringValue()
+		case "team":
+			filter.Team = opt.StringValue()
+		case "domain":
+			filter.Domain = strings.TrimPrefix(strings.TrimSpace(opt.StringValue()), "@")
+		case "joined_after":
+			after, err := time.Parse("2006-01-02", opt.StringValue())
+			if err != nil {
+				b.respondEphemeral(s, i, "❌ Invalid `joined_after` date. Use the format YYYY-MM-DD.")
+				return
+			}
+			filter.JoinedAfter = &after
+		case "joined_before":
+			before, err := time.Parse("2006-01-02", opt.StringValue())
+			if err != nil {
+				b.respondEphemeral(s, i, "❌ Invalid `joined_before` date. Use the format YYYY-MM-DD.")
+				return
+			}
+			filter.JoinedBefore = &before
+		case "page":
+			page = int(opt.IntValue())
+		}
+	}
+
+	LogDebug("Moderator %s requested user list page %d", i.Member.User.Username, page)
+
+	data, err := b.userListPage(filter, page)
+	if err != nil {
+		LogError("Error getting users: %v", err)
+		b.respondEphemeral(s, i, "❌ Error retrieving user list.")
+		return
+	}
+
+	data.Flags = discordgo.MessageFlagsEphemeral
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+}
+
+// handleListPage responds to the Prev/Next buttons on a /heimdall-list message
+func (b *Bot) handleListPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	filter, page, err := decodeListState(strings.TrimPrefix(i.MessageComponentData().CustomID, listCustomIDPrefix))
+	if err != nil {
+		LogWarn("Invalid user list button state: %v", err)
+		b.respondEphemeral(s, i, "❌ This list is out of date. Please run `/heimdall-list` again.")
+		return
+	}
+
+	data, err := b.userListPage(filter, page)
+	if err != nil {
+		LogError("Error getting users: %v", err)
+		b.respondEphemeral(s, i, "❌ Error retrieving user list.")
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+}
+
+// userListPage builds the embed and navigation buttons for one page of the user list
+func (b *Bot) userListPage(filter UserFilter, page int) (*discordgo.InteractionResponseData, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	users, total, err := b.db.ListUsers(filter, listPageSize, (page-1)*listPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if total == 0 {
+		LogDebug("User list empty")
+		return &discordgo.InteractionResponseData{
+			Content:    "No users match those filters.",
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		}, nil
+	}
+
+	totalPages := (total + listPageSize - 1) / listPageSize
+	if page > totalPages {
+		page = totalPages
+		users, _, err = b.db.ListUsers(filter, listPageSize, (page-1)*listPageSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	LogInfo("User list retrieved: page %d of %d (%d users)", page, totalPages, total)
+
+	var description strings.Builder
+	for _, user := range users {
+		description.WriteString(fmt.Sprintf("**%s**\n└ %s\n└ %s\n└ Joined %s\n\n",
+			user.DiscordUsername, user.Email, userStatusLabel(&user), user.CreatedAt.Format("2006-01-02")))
+	}
+
+	footer := fmt.Sprintf("Page %d of %d • %d users", page, totalPages, total)
+	if summary := describeUserFilter(filter); summary != "" {
+		footer += " • " + summary
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "👥 User List",
+		Description: truncate(description.String(), 4096),
+		Color:       0x667eea,
+		Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+	}
+
+	data := &discordgo.InteractionResponseData{
+		Content:    "",
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: []discordgo.MessageComponent{},
+	}
+
+	if totalPages > 1 {
+		prevID := encodeListState(filter, page-1)
+		nextID := encodeListState(filter, page+1)
+		if len(prevID) <= maxCustomIDLength && len(nextID) <= maxCustomIDLength {
+			data.Components = []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "◀ Prev",
+							Style:    discordgo.SecondaryButton,
+							CustomID: prevID,
+							Disabled: page <= 1,
+						},
+						discordgo.Button{
+							Label:    "Next ▶",
+							Style:    discordgo.SecondaryButton,
+							CustomID: nextID,
+							Disabled: page >= totalPages,
+						},
+					},
+				},
+			}
+		} else {
+			// Filters too long to fit in a button; fall back to the page option
+			embed.Footer.Text += " • Use the page option to see more"
+		}
+	}
+
+	return data, nil
+}
+
+// encodeListState packs a filter and page into a button custom ID
+func encodeListState(filter UserFilter, page int) string {
+	values := url.Values{}
+	values.Set("p", strconv.Itoa(page))
+	if filter.Status != "" {
+		values.Set("s", filter.Status)
+	}
+	if filter.Team != "" {
+		values.Set("t", filter.Team)
+	}
+	if filter.Domain != "" {
+		values.Set("d", filter.Domain)
+	}
+	if filter.JoinedAfter != nil {
+		values.Set("a", filter.JoinedAfter.Format("2006-01-02"))
+	}
+	if filter.JoinedBefore != nil {
+		values.Set("b", filter.JoinedBefore.Format("2006-01-02"))
+	}
+	return listCustomIDPrefix + values.Encode()
+}
+
+// decodeListState reverses encodeListState
+func decodeListState(encoded string) (UserFilter, int, error) {
+	var filter UserFilter
+
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return filter, 0, err
+	}
+
+	page, err := strconv.Atoi(values.Get("p"))
+	if err != nil {
+		return filter, 0, fmt.Errorf("invalid page: %w", err)
+	}
+
+	filter.Status = values.Get("s")
+	filter.Team = values.Get("t")
+	filter.Domain = values.Get("d")
+	if a := values.Get("a"); a != "" {
+		after, err := time.Parse("2006-01-02", a)
+		if err != nil {
+			return filter, 0, err
+		}
+		filter.JoinedAfter = &after
+	}
+	if bv := values.Get("b"); bv != "" {
+		before, err := time.Parse("2006-01-02", bv)
+		if err != nil {
+			return filter, 0, err
+		}
+		filter.JoinedBefore = &before
+	}
+
+	return filter, page, nil
+}
+
+// describeUserFilter summarises the active filters for the list footer
+func describeUserFilter(filter UserFilter) string {
+	var parts []string
+	if filter.Status != "" {
+		parts = append(parts, "status: "+filter.Status)
+	}
+	if filter.Team != "" {
+		parts = append(parts, "team: "+filter.Team)
+	}
+	if filter.Domain != "" {
+		parts = append(parts, "domain: "+filter.Domain)
+	}
+	if filter.JoinedAfter != nil {
+		parts = append(parts, "joined after: "+filter.JoinedAfter.Format("2006-01-02"))
+	}
+	if filter.JoinedBefore != nil {
+		parts = append(parts, "joined before: "+filter.JoinedBefore.Format("2006-01-02"))
+	}
+	return strings.Join(parts, ", ")
+}
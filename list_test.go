@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListStateRoundTrip(t *testing.T) {
+	after := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter UserFilter
+		page   int
+	}{
+		{"no filter", UserFilter{}, 1},
+		{"status", UserFilter{Status: "pending"}, 3},
+		{"team with spaces and symbols", UserFilter{Team: "R&D / Platform"}, 2},
+		{"domain", UserFilter{Domain: "company.co.uk"}, 10},
+		{"dates", UserFilter{JoinedAfter: &after, JoinedBefore: &before}, 1},
+		{"everything", UserFilter{Status: "verified", Team: "Engineering", Domain: "company.com", JoinedAfter: &after, JoinedBefore: &before}, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := encodeListState(tt.filter, tt.page)
+			if !strings.HasPrefix(encoded, listCustomIDPrefix) {
+				t.Fatalf("encodeListState = %q, want the %q prefix", encoded, listCustomIDPrefix)
+			}
+
+			filter, page, err := decodeListState(strings.TrimPrefix(encoded, listCustomIDPrefix))
+			if err != nil {
+				t.Fatalf("decodeListState(%q) returned error: %v", encoded, err)
+			}
+			if page != tt.page {
+				t.Errorf("page = %d, want %d", page, tt.page)
+			}
+			if filter.Status != tt.filter.Status || filter.Team != tt.filter.Team || filter.Domain != tt.filter.Domain {
+				t.Errorf("filter = %+v, want %+v", filter, tt.filter)
+			}
+			if !sameDay(filter.JoinedAfter, tt.filter.JoinedAfter) || !sameDay(filter.JoinedBefore, tt.filter.JoinedBefore) {
+				t.Errorf("dates = %v/%v, want %v/%v", filter.JoinedAfter, filter.JoinedBefore, tt.filter.JoinedAfter, tt.filter.JoinedBefore)
+			}
+		})
+	}
+}
+
+func sameDay(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+func TestDecodeListStateInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"s=pending",
+		"p=next",
+		"p=2&a=yesterday",
+		"p=2&b=2025-13-01",
+		"p=2&%zz",
+	}
+
+	for _, encoded := range tests {
+		if _, _, err := decodeListState(encoded); err == nil {
+			t.Errorf("decodeListState(%q) returned no error", encoded)
+		}
+	}
+}
+
+func TestListStateLength(t *testing.T) {
+	after := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// Every filter set with typical values has to fit in a button
+	typical := UserFilter{Status: "restricted", Team: "Engineering", Domain: "company.co.uk", JoinedAfter: &after, JoinedBefore: &before}
+	if encoded := encodeListState(typical, 999); len(encoded) > maxCustomIDLength {
+		t.Errorf("typical filter encodes to %d characters, over the %d limit: %q", len(encoded), maxCustomIDLength, encoded)
+	}
+
+	// Long filters can't fit, and the list falls back to the page option
+	long := UserFilter{Team: strings.Repeat("t", 50), Domain: strings.Repeat("d", 50) + ".com"}
+	if encoded := encodeListState(long, 1); len(encoded) <= maxCustomIDLength {
+		t.Errorf("long filter encodes to %d characters, expected it to exceed %d", len(encoded), maxCustomIDLength)
+	}
+}
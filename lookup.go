@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// lookupHistoryLimit is how many restriction history entries /heimdall-lookup shows
+const lookupHistoryLimit = 5
+
+func (b *Bot) handleLookup(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) != 1 {
+		b.respondEphemeral(s, i, "❌ Please provide exactly one of user, email or username.")
+		return
+	}
+
+	var user *User
+	var err error
+	var identifierType, identifier string
+
+	switch opt := options[0]; opt.Name {
+	case "user":
+		userOption := opt.UserValue(s)
+		identifierType, identifier = "Discord user", userOption.Username
+		user, err = b.db.GetUserByDiscordID(userOption.ID)
+	case "email":
+		identifierType, identifier = "email", strings.TrimSpace(strings.ToLower(opt.StringValue()))
+		if normalized, err := normalizeEmail(identifier); err == nil {
+			identifier = normalized
+		}
+		user, err = b.db.GetUserByEmail(identifier, b.config().CanonicalEmail(identifier))
+	case "username":
+		identifierType, identifier = "username", strings.TrimSpace(opt.StringValue())
+		user, err = b.db.GetUserByUsername(identifier)
+	}
+
+	LogDebug("Moderator %s looked up %s: %s", i.Member.User.Username, identifierType, identifier)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			b.respondEphemeral(s, i, fmt.Sprintf("❌ No user found with %s: `%s`", identifierType, identifier))
+		} else {
+			LogError("Error looking up user %s: %v", identifier, err)
+			b.respondEphemeral(s, i, "❌ Error retrieving user information.")
+		}
+		return
+	}
+
+	emailValue := user.Email
+	if user.EmailCanonical != "" && user.EmailCanonical != user.Email {
+		emailValue += fmt.Sprintf("\n(canonical: %s)", user.EmailCanonical)
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Discord", Value: fmt.Sprintf("<@%s>\n%s", user.DiscordID, user.DiscordUsername), Inline: true},
+		{Name: "Email", Value: emailValue, Inline: true},
+		{Name: "Status", Value: userStatusLabel(user), Inline: true},
+	}
+
+	if len(user.Teams) == 1 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Team", Value: user.Teams[0], Inline: true})
+	} else if len(user.Teams) > 1 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Teams", Value: user.TeamNames(), Inline: true})
+	}
+
+	fields = append(fields, &discordgo.MessageEmbedField{Name: "Created", Value: discordTimestamp(user.CreatedAt), Inline: true})
+	if user.VerifiedAt != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Verified", Value: discordTimestamp(*user.VerifiedAt), Inline: true})
+	}
+	if user.Unverified {
+		ends := "When a moderator unrestricts them"
+		if user.RestrictedUntil != nil {
+			ends = discordTimestamp(*user.RestrictedUntil)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Restriction Ends", Value: ends, Inline: true})
+	}
+	if user.Verified && b.config().Reverification.Enabled {
+		value := "Due " + discordTimestamp(user.ReverificationDue(b.config().ReverificationInterval()))
+		if user.ReverifyAskedAt != nil {
+			value = "Asked to confirm by " + discordTimestamp(user.ReverifyAskedAt.Add(b.config().ReverificationGrace()))
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Reverification", Value: value, Inline: true})
+	}
+	if user.IsPending() {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Link Expires",
+			Value:  discordTimestamp(user.CodeIssuedAt.Add(b.config().CodeTTL())),
+			Inline: true,
+		})
+	}
+
+	fields = append(fields,
+		&discordgo.MessageEmbedField{Name: "Restriction History", Value: b.restrictionHistory(user)},
+		&discordgo.MessageEmbedField{Name: "Discord Roles", Value: b.roleCheck(s, user)},
+	)
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "🔎 User Lookup",
+		Color:  0x667eea,
+		Fields: fields,
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// restrictionHistory summarises the most recent restrict/unrestrict actions on a user
+func (b *Bot) restrictionHistory(user *User) string {
+	filter := AuditFilter{
+		TargetID: user.DiscordID,
+		Actions:  []AuditAction{AuditActionRestrict, AuditActionUnrestrict},
+	}
+
+	entries, total, err := b.db.QueryAudit(filter, lookupHistoryLimit, 0)
+	if err != nil {
+		LogError("Error getting restriction history for %s: %v", user.DiscordUsername, err)
+		return "⚠️ Unable to load history"
+	}
+
+	if total == 0 {
+		return "None"
+	}
+
+	var history strings.Builder
+	for _, entry := range entries {
+		history.WriteString(fmt.Sprintf("%s **%s** by %s", discordTimestamp(entry.CreatedAt), entry.Action, entry.ActorName))
+		if entry.Reason != "" {
+			history.WriteString(": " + truncate(entry.Reason, 100))
+		}
+		history.WriteString("\n")
+	}
+	if total > len(entries) {
+		history.WriteString(fmt.Sprintf("…and %d more. See `/heimdall-audit`.", total-len(entries)))
+	}
+
+	return truncate(history.String(), 1024)
+}
+
+// roleCheck reports whether a user's current Discord roles match their database record
+func (b *Bot) roleCheck(s *discordgo.Session, user *User) string {
+	member, err := s.GuildMember(b.config().Discord.GuildID, user.DiscordID)
+	if err != nil {
+		LogDebug("Could not fetch guild member %s for role check: %v", user.DiscordUsername, err)
+		return "⚠️ Not in the server (or unable to fetch member)"
+	}
+
+	missing, extra := b.roleDrift(user, member.Roles)
+	if len(missing) == 0 && len(extra) == 0 {
+		return "✅ Roles match the database"
+	}
+
+	var check strings.Builder
+	check.WriteString("⚠️ Roles don't match the database\n")
+	if len(missing) > 0 {
+		check.WriteString("**Missing:** " + formatRoleMentions(missing) + "\n")
+	}
+	if len(extra) > 0 {
+		check.WriteString("**Shouldn't have:** " + formatRoleMentions(extra) + "\n")
+	}
+	return check.String()
+}
+
+// userStatusLabel renders a user's verification status for embeds
+func userStatusLabel(user *User) string {
+	if user.Verified {
+		return fmt.Sprintf("✅ Verified (%s)", user.TeamNames())
+	} else if user.Unverified {
+		return fmt.Sprintf("⚠️ Unverified (was %s)", user.TeamNames())
+	} else if user.AwaitingApproval {
+		return "🛂 Awaiting approval"
+	} else if user.ReverifyLapsed {
+		return fmt.Sprintf("⌛ Reverification lapsed (was %s)", user.TeamNames())
+	}
+	return "⏳ Pending"
+}
+
+// discordTimestamp renders a time that Discord shows in each viewer's own timezone
+func discordTimestamp(t time.Time) string {
+	return fmt.Sprintf("<t:%d:f>", t.Unix())
+}
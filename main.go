@@ -1,21 +1,48 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 )
 
+// Paths are relative to the working directory unless overridden with --config and --database
+var (
+	configPath   = "config.yaml"
+	databasePath = "data/heimdall.db"
+)
+
 func main() {
+	flag.StringVar(&configPath, "config", configPath, "path to the YAML config file")
+	flag.StringVar(&databasePath, "database", databasePath, "path to the SQLite database")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), cliUsage)
+	}
+	flag.Parse()
+
+	// Subcommands (e.g. "heimdall migrate status") run and exit without starting the bot
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
 	log.Println("Starting Heimdall...")
 
 	// Load configuration
 	log.Println("Loading configuration...")
-	config, err := LoadConfig("config.yaml")
+	config, err := LoadConfig(configPath)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
+	if err := config.Validate(); err != nil {
+		for _, problem := range err.(ConfigErrors) {
+			log.Printf("✗ %v", problem)
+		}
+		log.Fatalf("Invalid configuration in %s (run \"heimdall config check\" to re-check)", configPath)
+	}
 	log.Println("✓ Configuration loaded")
 
 	// Initialize logging system with config
@@ -23,46 +50,103 @@ func main() {
 	LogDebug("Log level: %s", GetLogLevel())
 	LogDebug("Loaded config: Guild=%s, Port=%d, Teams=%d", config.Discord.GuildID, config.Server.Port, len(config.Teams))
 
+	// Components read the config through the store so reloads apply without a restart
+	configs := NewConfigStore(configPath, config)
+
 	// Initialize database
 	log.Println("Initializing database...")
 
 	// Ensure data directory exists
-	if err := os.MkdirAll("data", 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(databasePath), 0755); err != nil {
 		log.Fatalf("Error creating data directory: %v", err)
 	}
 
-	db, err := NewDatabase("data/heimdall.db")
+	db, err := NewDatabase(databasePath)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
 	defer db.Close()
 	log.Println("✓ Database initialized")
-	LogDebug("Database file: data/heimdall.db")
+	LogDebug("Database file: %s", databasePath)
 
 	// Initialize email service
 	log.Println("Initializing email service...")
-	emailService := NewEmailService(config)
+	emailService := NewEmailService(configs)
 	log.Println("✓ Email service ready")
 	LogDebug("SMTP: %s:%d", config.Email.SMTPHost, config.Email.SMTPPort)
 
+	// Initialize event bus for audit channel mirroring
+	events := NewEventBus(100)
+
 	// Initialize Discord bot
 	log.Println("Initializing Discord bot...")
-	bot, err := NewBot(config.Discord.Token, config, db, emailService)
+	bot, err := NewBot(config.Discord.Token, configs, db, emailService, events)
 	if err != nil {
 		log.Fatalf("Error creating bot: %v", err)
 	}
 	defer bot.Close()
 	LogDebug("Bot instance created")
 
+	// Bring canonical emails in line with the email_normalization settings
+	bot.recanonicalizeEmails()
+
+	// Domains may also be added at runtime, so an empty approved_domains is only a warning
+	if len(bot.approvedDomainPatterns()) == 0 {
+		LogWarn("No approved domains configured; nobody can verify until one is added with /heimdall-domains add")
+	}
+
+	events.Subscribe(bot.postAuditEvent)
+	events.Start()
+	defer events.Stop()
+	if config.Discord.AuditChannelID != "" {
+		LogDebug("Audit channel: %s", config.Discord.AuditChannelID)
+	}
+
 	// Start Discord bot
 	log.Println("Connecting to Discord...")
 	if err := bot.Start(); err != nil {
 		log.Fatalf("Error starting bot: %v", err)
 	}
 
+	// Start janitor for expired verification codes
+	janitor := NewJanitor(configs, db, bot)
+	janitor.Start()
+	defer janitor.Stop()
+	LogDebug("Verification codes expire after %s, cleanup every %s", config.CodeTTL(), config.CleanupInterval())
+
+	// Lift time-limited restrictions as they run out, including any that lapsed while offline
+	restrictions := NewRestrictionScheduler(db, bot)
+	restrictions.Start()
+	defer restrictions.Stop()
+
+	// Ask members to reconfirm their email periodically; it idles while reverification.enabled is off
+	reverifier := NewReverifier(configs, db, bot)
+	reverifier.Start()
+	defer reverifier.Stop()
+
+	// Restrict members who drop off the employee roster; it idles while roster.enabled is off
+	rosterWatcher := NewRosterWatcher(configs, bot)
+	rosterWatcher.Start()
+	defer rosterWatcher.Stop()
+
+	// Start scheduled role sync; it idles while role_sync.enabled is off
+	roleSyncer := NewRoleSyncer(configs, bot)
+	roleSyncer.Start()
+	defer roleSyncer.Stop()
+	if config.RoleSync.Enabled {
+		LogDebug("Role sync every %s (dry run: %t)", config.RoleSyncInterval(), config.RoleSync.DryRun)
+	}
+
+	// Reload config.yaml when it changes on disk or on SIGHUP
+	configs.OnReload(bot.onConfigReload)
+	configs.OnReload(janitor.onConfigReload)
+	configs.OnReload(roleSyncer.onConfigReload)
+	configs.Watch()
+	defer configs.Stop()
+
 	// Initialize web server
 	log.Println("Initializing web server...")
-	webServer := NewWebServer(config, db, bot)
+	webServer := NewWebServer(configs, db, bot, events)
 	
 	// Start web server in goroutine
 	log.Printf("Web server starting on port %d", config.Server.Port)
@@ -80,10 +164,18 @@ func main() {
 	log.Println("Press CTRL-C to exit")
 	log.Println("")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the config on SIGHUP
 	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP)
+	for sig := range sc {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		LogInfo("Received SIGHUP, reloading %s", configPath)
+		if err := configs.Reload(); err != nil {
+			LogError("Config reload failed, keeping previous configuration: %v", err)
+		}
+	}
 
 	log.Println("")
 	log.Println("Shutting down gracefully...")
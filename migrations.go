@@ -0,0 +1,372 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a single, ordered schema change. Migrations are append-only:
+// never edit or reorder one that has shipped, add a new one instead.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// MigrationStatus describes whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create users table",
+		up: execSQL(`
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				discord_id TEXT UNIQUE NOT NULL,
+				discord_username TEXT NOT NULL,
+				email TEXT UNIQUE NOT NULL,
+				verification_code TEXT UNIQUE NOT NULL,
+				team_role TEXT,
+				verified BOOLEAN DEFAULT FALSE,
+				unverified BOOLEAN DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				verified_at DATETIME
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_discord_id ON users(discord_id);
+			CREATE INDEX IF NOT EXISTS idx_email ON users(email);
+			CREATE INDEX IF NOT EXISTS idx_verification_code ON users(verification_code);
+		`),
+	},
+	{
+		// Columns may already exist on databases upgraded before migrations were tracked
+		version: 2,
+		name:    "add verification code expiry",
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "users", "code_issued_at", "DATETIME"); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`UPDATE users SET code_issued_at = created_at WHERE code_issued_at IS NULL`)
+			return err
+		},
+	},
+	{
+		version: 3,
+		name:    "add resend tracking",
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "users", "resend_count", "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "users", "resend_window_at", "DATETIME")
+		},
+	},
+	{
+		version: 4,
+		name:    "create audit log",
+		up: execSQL(`
+			CREATE TABLE audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				action TEXT NOT NULL,
+				actor_id TEXT NOT NULL,
+				actor_name TEXT NOT NULL,
+				target_id TEXT NOT NULL,
+				target_name TEXT NOT NULL,
+				reason TEXT,
+				before_state TEXT,
+				after_state TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX idx_audit_target ON audit_log(target_id);
+			CREATE INDEX idx_audit_actor ON audit_log(actor_id);
+			CREATE INDEX idx_audit_action ON audit_log(action);
+			CREATE INDEX idx_audit_created_at ON audit_log(created_at);
+		`),
+	},
+	{
+		version: 5,
+		name:    "create approved domains",
+		up: execSQL(`
+			CREATE TABLE approved_domains (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				domain TEXT UNIQUE NOT NULL,
+				added_by_id TEXT NOT NULL,
+				added_by_name TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+		`),
+	},
+	{
+		version: 6,
+		name:    "create email allowlist and blocklist",
+		up: execSQL(`
+			CREATE TABLE email_rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email TEXT UNIQUE NOT NULL,
+				rule TEXT NOT NULL,
+				reason TEXT,
+				added_by_id TEXT NOT NULL,
+				added_by_name TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX idx_email_rules_rule ON email_rules(rule);
+		`),
+	},
+	{
+		// Backfilled with the lowercased address; the bot recomputes it from
+		// the email_normalization settings at startup
+		version: 7,
+		name:    "add canonical email",
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "users", "email_canonical", "TEXT"); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`
+				UPDATE users SET email_canonical = lower(email) WHERE email_canonical IS NULL;
+				CREATE UNIQUE INDEX idx_email_canonical ON users(email_canonical);
+			`)
+			return err
+		},
+	},
+	{
+		// users.team_role is left in place for older versions but is no
+		// longer read or written
+		version: 8,
+		name:    "create user teams",
+		up: execSQL(`
+			CREATE TABLE user_teams (
+				discord_id TEXT NOT NULL,
+				team TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (discord_id, team)
+			);
+
+			CREATE INDEX idx_user_teams_team ON user_teams(team);
+
+			INSERT INTO user_teams (discord_id, team)
+			SELECT discord_id, team_role FROM users WHERE COALESCE(team_role, '') != '';
+		`),
+	},
+	{
+		version: 9,
+		name:    "create team change requests",
+		up: execSQL(`
+			CREATE TABLE team_change_requests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				discord_id TEXT NOT NULL,
+				discord_username TEXT NOT NULL,
+				action TEXT NOT NULL,
+				team TEXT NOT NULL,
+				status TEXT NOT NULL,
+				channel_id TEXT,
+				message_id TEXT,
+				decided_by_id TEXT,
+				decided_by_name TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				decided_at DATETIME
+			);
+
+			CREATE INDEX idx_team_change_requests_user ON team_change_requests(discord_id, status);
+		`),
+	},
+	{
+		version: 10,
+		name:    "add verification approvals",
+		up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "users", "awaiting_approval", "BOOLEAN DEFAULT 0"); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`
+				CREATE TABLE verification_approvals (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					discord_id TEXT NOT NULL,
+					discord_username TEXT NOT NULL,
+					email TEXT NOT NULL,
+					status TEXT NOT NULL,
+					channel_id TEXT,
+					message_id TEXT,
+					decided_by_id TEXT,
+					decided_by_name TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					decided_at DATETIME
+				);
+
+				CREATE INDEX idx_verification_approvals_user ON verification_approvals(discord_id, status);
+			`)
+			return err
+		},
+	},
+	{
+		version: 11,
+		name:    "add restriction expiry",
+		up: func(tx *sql.Tx) error {
+			return addColumnIfMissing(tx, "users", "restricted_until", "DATETIME")
+		},
+	},
+	{
+		version: 12,
+		name:    "add reverification tracking",
+		up: func(tx *sql.Tx) error {
+			return addColumnIfMissing(tx, "users", "reverify_requested_at", "DATETIME")
+		},
+	},
+	{
+		version: 13,
+		name:    "add lapsed reverification state",
+		up: func(tx *sql.Tx) error {
+			return addColumnIfMissing(tx, "users", "reverify_lapsed", "BOOLEAN DEFAULT FALSE")
+		},
+	},
+}
+
+// execSQL builds a migration step from a plain SQL script
+func execSQL(script string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(script)
+		return err
+	}
+}
+
+// Migrate applies every migration newer than the database's current version,
+// each in its own transaction
+func (d *Database) Migrate() error {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, latest)
+		}
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		LogInfo("Applying database migration %d: %s", m.version, m.name)
+		if err := d.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrationStatus lists every known migration and when it was applied, if at all
+func (d *Database) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := d.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if appliedAt, ok := applied[m.version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (d *Database) applyMigration(m migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedMigrations returns the applied migration versions and when each was applied
+func (d *Database) appliedMigrations() (map[int]time.Time, error) {
+	_, err := d.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// addColumnIfMissing adds a column to an existing table if it isn't already present
+func addColumnIfMissing(db execer, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			found = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if found {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
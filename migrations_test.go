@@ -0,0 +1,180 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// baselineSchema is the users table as created before migrations were tracked
+const baselineSchema = `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		discord_id TEXT UNIQUE NOT NULL,
+		discord_username TEXT NOT NULL,
+		email TEXT UNIQUE NOT NULL,
+		verification_code TEXT UNIQUE NOT NULL,
+		team_role TEXT,
+		verified BOOLEAN DEFAULT FALSE,
+		unverified BOOLEAN DEFAULT FALSE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		verified_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_discord_id ON users(discord_id);
+	CREATE INDEX IF NOT EXISTS idx_email ON users(email);
+	CREATE INDEX IF NOT EXISTS idx_verification_code ON users(verification_code);
+`
+
+// openTestDatabase opens an empty in-memory database without migrating it
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := OpenDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("OpenDatabase: %v", err)
+	}
+	// Every connection to :memory: gets its own database, so keep to one
+	db.db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, db *Database) []int {
+	t.Helper()
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	var versions []int
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			versions = append(versions, status.Version)
+		}
+	}
+	return versions
+}
+
+func allVersions() []int {
+	versions := make([]int, len(migrations))
+	for n, m := range migrations {
+		versions[n] = m.version
+	}
+	return versions
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for n, m := range migrations {
+		if m.version != n+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, n+1)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("first Migrate: %v", err)
+	}
+	if got := appliedVersions(t, db); !slices.Equal(got, allVersions()) {
+		t.Fatalf("applied versions = %v, want %v", got, allVersions())
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	var count int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Errorf("schema_migrations has %d rows after migrating twice, want %d", count, len(migrations))
+	}
+
+	if _, err := db.db.Exec(`INSERT INTO users (discord_id, discord_username, email, verification_code) VALUES ('1', 'alice', 'alice@company.com', 'code-1')`); err != nil {
+		t.Fatalf("inserting into migrated schema: %v", err)
+	}
+	user, err := db.GetUserByDiscordID("1")
+	if err != nil {
+		t.Fatalf("GetUserByDiscordID on migrated schema: %v", err)
+	}
+	if !user.IsPending() {
+		t.Errorf("new user should be pending: %+v", user)
+	}
+}
+
+func TestMigrateFromBaseline(t *testing.T) {
+	db := openTestDatabase(t)
+
+	if _, err := db.db.Exec(baselineSchema); err != nil {
+		t.Fatalf("creating baseline schema: %v", err)
+	}
+	_, err := db.db.Exec(`
+		INSERT INTO users (discord_id, discord_username, email, verification_code, team_role, verified, verified_at)
+		VALUES ('1', 'alice', 'Alice@Company.com', 'code-1', 'Engineering', TRUE, CURRENT_TIMESTAMP);
+		INSERT INTO users (discord_id, discord_username, email, verification_code)
+		VALUES ('2', 'bob', 'bob@company.com', 'code-2');
+	`)
+	if err != nil {
+		t.Fatalf("inserting baseline rows: %v", err)
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate from baseline: %v", err)
+	}
+	if got := appliedVersions(t, db); !slices.Equal(got, allVersions()) {
+		t.Fatalf("applied versions = %v, want %v", got, allVersions())
+	}
+
+	alice, err := db.GetUserByDiscordID("1")
+	if err != nil {
+		t.Fatalf("GetUserByDiscordID(alice): %v", err)
+	}
+	if !alice.Verified {
+		t.Errorf("alice should still be verified: %+v", alice)
+	}
+	if !slices.Equal(alice.Teams, []string{"Engineering"}) {
+		t.Errorf("alice's teams = %v, want her team_role carried over", alice.Teams)
+	}
+
+	bob, err := db.GetUserByDiscordID("2")
+	if err != nil {
+		t.Fatalf("GetUserByDiscordID(bob): %v", err)
+	}
+	if !bob.IsPending() || len(bob.Teams) != 0 {
+		t.Errorf("bob should be pending with no teams: %+v", bob)
+	}
+	var missingIssuedAt int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM users WHERE code_issued_at IS NULL`).Scan(&missingIssuedAt); err != nil {
+		t.Fatal(err)
+	}
+	if missingIssuedAt != 0 {
+		t.Errorf("%d users have no code_issued_at after the backfill", missingIssuedAt)
+	}
+
+	var canonical string
+	if err := db.db.QueryRow(`SELECT email_canonical FROM users WHERE discord_id = '1'`).Scan(&canonical); err != nil {
+		t.Fatal(err)
+	}
+	if canonical != "alice@company.com" {
+		t.Errorf("alice's email_canonical = %q, want alice@company.com", canonical)
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("second Migrate after upgrade: %v", err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	future := migrations[len(migrations)-1].version + 1
+	if _, err := db.db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')`, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(); err == nil {
+		t.Error("Migrate should refuse a database with a newer schema version")
+	}
+}
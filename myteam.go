@@ -0,0 +1,464 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// TeamChangeStatus tracks a /heimdall-myteam request through the approval queue
+type TeamChangeStatus string
+
+const (
+	TeamChangePending   TeamChangeStatus = "pending"   // Waiting for a moderator
+	TeamChangeApplied   TeamChangeStatus = "applied"   // Applied straight away, no approval needed
+	TeamChangeApproved  TeamChangeStatus = "approved"  // Approved by a moderator and applied
+	TeamChangeDenied    TeamChangeStatus = "denied"    // Turned down by a moderator
+	TeamChangeCancelled TeamChangeStatus = "cancelled" // No longer applied by the time a moderator looked at it
+)
+
+// teamRequestCustomIDPrefix starts the custom ID of the Approve/Deny buttons;
+// the rest is "approve:<id>" or "deny:<id>"
+const teamRequestCustomIDPrefix = "heimdall-myteam:"
+
+// TeamChangeRequest is a user's request to change their own teams
+type TeamChangeRequest struct {
+	ID              int64
+	DiscordID       string
+	DiscordUsername string
+	Action          string
+	Team            string
+	Status          TeamChangeStatus
+	ChannelID       string // Approval message, if one was posted
+	MessageID       string
+	DecidedByID     string
+	DecidedByName   string
+	CreatedAt       time.Time
+	DecidedAt       *time.Time
+}
+
+const teamRequestColumns = `id, discord_id, discord_username, action, team, status,
+		COALESCE(channel_id, ''), COALESCE(message_id, ''), COALESCE(decided_by_id, ''), COALESCE(decided_by_name, ''),
+		created_at, decided_at`
+
+func scanTeamChangeRequest(row rowScanner) (*TeamChangeRequest, error) {
+	var req TeamChangeRequest
+	var status string
+	var decidedAt sql.NullTime
+	err := row.Scan(&req.ID, &req.DiscordID, &req.DiscordUsername, &req.Action, &req.Team, &status,
+		&req.ChannelID, &req.MessageID, &req.DecidedByID, &req.DecidedByName, &req.CreatedAt, &decidedAt)
+	if err != nil {
+		return nil, err
+	}
+	req.Status = TeamChangeStatus(status)
+	if decidedAt.Valid {
+		req.DecidedAt = &decidedAt.Time
+	}
+	return &req, nil
+}
+
+// CreateTeamChangeRequest stores a request and returns its ID. Requests that
+// are already applied are stamped as decided by the user themselves.
+func (d *Database) CreateTeamChangeRequest(req TeamChangeRequest) (int64, error) {
+	query := `
+		INSERT INTO team_change_requests (discord_id, discord_username, action, team, status, decided_by_id, decided_by_name, decided_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CASE WHEN ? THEN CURRENT_TIMESTAMP END)
+	`
+	applied := req.Status == TeamChangeApplied
+	var decidedByID, decidedByName interface{}
+	if applied {
+		decidedByID, decidedByName = req.DiscordID, req.DiscordUsername
+	}
+	result, err := d.db.Exec(query, req.DiscordID, req.DiscordUsername, req.Action, req.Team, string(req.Status), decidedByID, decidedByName, applied)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (d *Database) GetTeamChangeRequest(id int64) (*TeamChangeRequest, error) {
+	query := `SELECT ` + teamRequestColumns + ` FROM team_change_requests WHERE id = ?`
+	return scanTeamChangeRequest(d.db.QueryRow(query, id))
+}
+
+// GetPendingTeamChangeRequest returns the user's request awaiting approval, or sql.ErrNoRows
+func (d *Database) GetPendingTeamChangeRequest(discordID string) (*TeamChangeRequest, error) {
+	query := `SELECT ` + teamRequestColumns + ` FROM team_change_requests WHERE discord_id = ? AND status = ? ORDER BY id DESC LIMIT 1`
+	return scanTeamChangeRequest(d.db.QueryRow(query, discordID, string(TeamChangePending)))
+}
+
+// SetTeamChangeRequestMessage records where a request's approval message was posted
+func (d *Database) SetTeamChangeRequestMessage(id int64, channelID, messageID string) error {
+	_, err := d.db.Exec(`UPDATE team_change_requests SET channel_id = ?, message_id = ? WHERE id = ?`, channelID, messageID, id)
+	return err
+}
+
+// DecideTeamChangeRequest records a decision on a pending request. It returns
+// false if the request was no longer pending, e.g. two moderators clicked at once.
+func (d *Database) DecideTeamChangeRequest(id int64, status TeamChangeStatus, decidedByID, decidedByName string) (bool, error) {
+	query := `
+		UPDATE team_change_requests
+		SET status = ?, decided_by_id = ?, decided_by_name = ?, decided_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`
+	result, err := d.db.Exec(query, string(status), decidedByID, decidedByName, id, string(TeamChangePending))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// LastTeamChangeAt returns when the user's most recent self-service change
+// took effect, or nil if they've never made one
+func (d *Database) LastTeamChangeAt(discordID string) (*time.Time, error) {
+	query := `
+		SELECT decided_at FROM team_change_requests
+		WHERE discord_id = ? AND status IN (?, ?) AND decided_at IS NOT NULL
+		ORDER BY decided_at DESC LIMIT 1
+	`
+	var decidedAt time.Time
+	err := d.db.QueryRow(query, discordID, string(TeamChangeApplied), string(TeamChangeApproved)).Scan(&decidedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &decidedAt, nil
+}
+
+// describeTeamAction renders a requested change, e.g. "Join **Product**"
+func describeTeamAction(action, team string) string {
+	switch action {
+	case TeamActionAdd:
+		return fmt.Sprintf("Join **%s**", team)
+	case TeamActionRemove:
+		return fmt.Sprintf("Leave **%s**", team)
+	default:
+		return fmt.Sprintf("Switch to **%s**", team)
+	}
+}
+
+func (b *Bot) handleMyTeam(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var team string
+	action := TeamActionSet
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "team":
+			team = opt.StringValue()
+		case "action":
+			action = opt.StringValue()
+		}
+	}
+
+	member := i.Member.User
+	LogInfo("User %s requested team change: action=%s team=%s", member.Username, action, team)
+
+	user, err := b.db.GetUserByDiscordID(member.ID)
+	if err != nil && err != sql.ErrNoRows {
+		LogError("Error getting user for team change %s: %v", member.Username, err)
+		b.respondEphemeral(s, i, "❌ An error occurred. Please try again later.")
+		return
+	}
+	if user == nil || !user.Verified || user.Unverified {
+		b.respondEphemeral(s, i, "❌ Only verified members can change their team.")
+		return
+	}
+
+	settings := b.config().DomainSettingsFor(user.Email)
+	if settings.LockedTeam != "" {
+		b.respondEphemeral(s, i, "❌ Your team is set by your email domain and can't be changed here. Please ask a moderator.")
+		return
+	}
+
+	choices := settings.TeamChoices(b.config().Teams)
+	if !slices.Contains(choices, team) {
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ Team '%s' isn't available.\n\n**Available teams:** %s", team, strings.Join(choices, ", ")))
+		return
+	}
+
+	switch checkTeamChange(user, action, team) {
+	case errAlreadyOnTeam:
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ You're already on the **%s** team.", team))
+		return
+	case errNotOnTeam:
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ You're not on the **%s** team.", team))
+		return
+	case errLastTeam:
+		b.respondEphemeral(s, i, fmt.Sprintf("❌ **%s** is your only team. Use `action:set` to switch to a different team.", team))
+		return
+	}
+
+	if _, err := b.db.GetPendingTeamChangeRequest(member.ID); err == nil {
+		b.respondEphemeral(s, i, "⏳ You already have a team change waiting for a moderator. You'll get a DM once it's reviewed.")
+		return
+	} else if err != sql.ErrNoRows {
+		LogError("Error checking pending team changes for %s: %v", member.Username, err)
+		b.respondEphemeral(s, i, "❌ An error occurred. Please try again later.")
+		return
+	}
+
+	last, err := b.db.LastTeamChangeAt(member.ID)
+	if err != nil {
+		LogError("Error checking team change cooldown for %s: %v", member.Username, err)
+		b.respondEphemeral(s, i, "❌ An error occurred. Please try again later.")
+		return
+	}
+	if last != nil {
+		if wait := b.config().TeamChangeCooldown() - time.Since(*last); wait > 0 {
+			b.respondEphemeral(s, i, fmt.Sprintf("⏳ You changed teams recently. You can change again in %s.", formatWait(wait)))
+			return
+		}
+	}
+
+	if b.config().TeamChanges.RequireApproval {
+		b.requestTeamChange(s, i, user, action, team)
+		return
+	}
+
+	newTeams, err := b.applyTeamChange(user, action, team)
+	if err != nil {
+		LogError("Error applying team change for %s: %v", member.Username, err)
+		b.respondEphemeral(s, i, "❌ Couldn't update your team. Please contact a moderator.")
+		return
+	}
+
+	request := TeamChangeRequest{DiscordID: member.ID, DiscordUsername: user.DiscordUsername, Action: action, Team: team, Status: TeamChangeApplied}
+	if _, err := b.db.CreateTeamChangeRequest(request); err != nil {
+		// The change itself worked; only the cooldown is lost
+		LogError("Error recording team change for %s: %v", member.Username, err)
+	}
+
+	b.audit(i, AuditActionChangeTeam, member.ID, user.DiscordUsername, "self-service", auditState(user), auditState(&User{Verified: true, Teams: newTeams}))
+	b.events.Publish(Event{
+		Type:         EventTeamChange,
+		UserID:       member.ID,
+		Username:     user.DiscordUsername,
+		Team:         strings.Join(newTeams, ", "),
+		PreviousTeam: user.TeamNames(),
+	})
+
+	LogSuccess("Self-service team change: %s moved from [%s] to [%s]", member.Username, user.TeamNames(), strings.Join(newTeams, ", "))
+	b.respondEphemeral(s, i, fmt.Sprintf("✅ You're now on %s.", teamPhrase(newTeams)))
+}
+
+// requestTeamChange posts a change to the approval channel for a moderator to decide
+func (b *Bot) requestTeamChange(s *discordgo.Session, i *discordgo.InteractionCreate, user *User, action, team string) {
+	request := TeamChangeRequest{
+		DiscordID:       user.DiscordID,
+		DiscordUsername: user.DiscordUsername,
+		Action:          action,
+		Team:            team,
+		Status:          TeamChangePending,
+		CreatedAt:       time.Now(),
+	}
+
+	id, err := b.db.CreateTeamChangeRequest(request)
+	if err != nil {
+		LogError("Error creating team change request for %s: %v", user.DiscordUsername, err)
+		b.respondEphemeral(s, i, "❌ An error occurred. Please try again later.")
+		return
+	}
+	request.ID = id
+
+	channelID := b.config().Discord.ApprovalChannelID
+	message, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{teamRequestEmbed(&request, user.Teams, "⏳ Waiting for a moderator")},
+		Components: teamRequestButtons(id),
+	})
+	if err != nil {
+		LogError("Error posting team change request for %s to approval channel: %v", user.DiscordUsername, err)
+		b.db.DecideTeamChangeRequest(id, TeamChangeCancelled, "", "")
+		b.respondEphemeral(s, i, "❌ Couldn't send your request to the moderators. Please try again later.")
+		return
+	}
+	if err := b.db.SetTeamChangeRequestMessage(id, channelID, message.ID); err != nil {
+		LogError("Error saving approval message for team change request %d: %v", id, err)
+	}
+
+	LogInfo("Team change request %d from %s sent for approval", id, user.DiscordUsername)
+	b.respondEphemeral(s, i, fmt.Sprintf("📨 Your request (%s) has been sent to the moderators. You'll get a DM once it's reviewed.", describeTeamAction(action, team)))
+}
+
+func teamRequestEmbed(request *TeamChangeRequest, currentTeams []string, status string) *discordgo.MessageEmbed {
+	current := strings.Join(currentTeams, ", ")
+	if current == "" {
+		current = "None"
+	}
+	return &discordgo.MessageEmbed{
+		Title: "📝 Team Change Request",
+		Color: 0x3498db,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "User", Value: fmt.Sprintf("<@%s>\n%s", request.DiscordID, request.DiscordUsername), Inline: true},
+			{Name: "Current Teams", Value: current, Inline: true},
+			{Name: "Requested", Value: describeTeamAction(request.Action, request.Team), Inline: true},
+			{Name: "Status", Value: status},
+		},
+		Timestamp: request.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func teamRequestButtons(id int64) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: fmt.Sprintf("%sapprove:%d", teamRequestCustomIDPrefix, id),
+				},
+				discordgo.Button{
+					Label:    "Deny",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%sdeny:%d", teamRequestCustomIDPrefix, id),
+				},
+			},
+		},
+	}
+}
+
+// handleTeamRequestButton handles Approve and Deny on a team change request
+func (b *Bot) handleTeamRequestButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to do this.")
+		return
+	}
+
+	decision, idText, _ := strings.Cut(strings.TrimPrefix(i.MessageComponentData().CustomID, teamRequestCustomIDPrefix), ":")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		LogWarn("Invalid team change request button: %s", i.MessageComponentData().CustomID)
+		b.respondEphemeral(s, i, "❌ This request is out of date.")
+		return
+	}
+
+	request, err := b.db.GetTeamChangeRequest(id)
+	if err == sql.ErrNoRows {
+		b.respondEphemeral(s, i, "❌ This request no longer exists. The user's data may have been purged.")
+		return
+	}
+	if err != nil {
+		LogError("Error getting team change request %d: %v", id, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+	if request.Status != TeamChangePending {
+		b.respondEphemeral(s, i, fmt.Sprintf("ℹ️ This request was already %s by %s.", request.Status, request.DecidedByName))
+		return
+	}
+
+	switch decision {
+	case "approve":
+		b.approveTeamRequest(s, i, request)
+	case "deny":
+		b.denyTeamRequest(s, i, request)
+	}
+}
+
+func (b *Bot) approveTeamRequest(s *discordgo.Session, i *discordgo.InteractionCreate, request *TeamChangeRequest) {
+	moderator := i.Member.User
+
+	// The user's situation may have changed while the request waited
+	user, err := b.db.GetUserByDiscordID(request.DiscordID)
+	var problem string
+	if err != nil || !user.Verified || user.Unverified {
+		problem = "the user is no longer verified"
+	} else if _, exists := b.config().Teams[request.Team]; !exists {
+		problem = "the team no longer exists"
+	} else if checkTeamChange(user, request.Action, request.Team) != nil {
+		problem = "it no longer fits the user's current teams"
+	}
+	if problem != "" {
+		if _, err := b.db.DecideTeamChangeRequest(request.ID, TeamChangeCancelled, moderator.ID, moderator.Username); err != nil {
+			LogError("Error cancelling team change request %d: %v", request.ID, err)
+		}
+		var teams []string
+		if user != nil {
+			teams = user.Teams
+		}
+		b.updateTeamRequestMessage(s, i, request, teams, "⚠️ Cancelled: "+problem)
+		return
+	}
+
+	claimed, err := b.db.DecideTeamChangeRequest(request.ID, TeamChangeApproved, moderator.ID, moderator.Username)
+	if err != nil {
+		LogError("Error approving team change request %d: %v", request.ID, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+	if !claimed {
+		b.respondEphemeral(s, i, "ℹ️ Another moderator has already handled this request.")
+		return
+	}
+
+	newTeams, err := b.applyTeamChange(user, request.Action, request.Team)
+	if err != nil {
+		LogError("Error applying approved team change %d for %s: %v", request.ID, user.DiscordUsername, err)
+		b.updateTeamRequestMessage(s, i, request, user.Teams, fmt.Sprintf("⚠️ Approved by <@%s>, but updating roles failed. Use `/heimdall-changeteam` to fix.", moderator.ID))
+		return
+	}
+
+	b.audit(i, AuditActionTeamApprove, user.DiscordID, user.DiscordUsername, "", auditState(user), auditState(&User{Verified: true, Teams: newTeams}))
+	b.events.Publish(Event{
+		Type:         EventTeamChange,
+		UserID:       user.DiscordID,
+		Username:     user.DiscordUsername,
+		ActorID:      moderator.ID,
+		ActorName:    moderator.Username,
+		Team:         strings.Join(newTeams, ", "),
+		PreviousTeam: user.TeamNames(),
+	})
+
+	LogSuccess("Team change request %d for %s approved by %s", request.ID, user.DiscordUsername, moderator.Username)
+	b.updateTeamRequestMessage(s, i, request, newTeams, fmt.Sprintf("✅ Approved by <@%s>", moderator.ID))
+	b.SendDM(user.DiscordID, fmt.Sprintf("✅ A moderator approved your team change. You're now on %s.", teamPhrase(newTeams)))
+}
+
+func (b *Bot) denyTeamRequest(s *discordgo.Session, i *discordgo.InteractionCreate, request *TeamChangeRequest) {
+	moderator := i.Member.User
+
+	claimed, err := b.db.DecideTeamChangeRequest(request.ID, TeamChangeDenied, moderator.ID, moderator.Username)
+	if err != nil {
+		LogError("Error denying team change request %d: %v", request.ID, err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+	if !claimed {
+		b.respondEphemeral(s, i, "ℹ️ Another moderator has already handled this request.")
+		return
+	}
+
+	var teams []string
+	user, err := b.db.GetUserByDiscordID(request.DiscordID)
+	if err == nil {
+		teams = user.Teams
+	} else {
+		user = nil
+	}
+
+	state := auditState(user)
+	b.audit(i, AuditActionTeamDeny, request.DiscordID, request.DiscordUsername, describeTeamAction(request.Action, request.Team), state, state)
+	LogInfo("Team change request %d for %s denied by %s", request.ID, request.DiscordUsername, moderator.Username)
+	b.updateTeamRequestMessage(s, i, request, teams, fmt.Sprintf("❌ Denied by <@%s>", moderator.ID))
+	b.SendDM(request.DiscordID, fmt.Sprintf("❌ A moderator declined your team change request (%s). Please contact a moderator if you have questions.", describeTeamAction(request.Action, request.Team)))
+}
+
+// updateTeamRequestMessage replaces the approval message's status and removes its buttons
+func (b *Bot) updateTeamRequestMessage(s *discordgo.Session, i *discordgo.InteractionCreate, request *TeamChangeRequest, teams []string, status string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{teamRequestEmbed(request, teams, status)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		LogError("Error updating approval message for team change request %d: %v", request.ID, err)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var errReconcileRunning = errors.New("role sync already running")
+
+// RoleDrift describes one guild member whose managed roles disagree with the database
+type RoleDrift struct {
+	UserID   string
+	Username string
+	Missing  []string
+	Extra    []string
+	Tracked  bool // False if the member has no row in the users table
+	Error    error
+}
+
+// ReconcileReport summarises a role sync run
+type ReconcileReport struct {
+	DryRun    bool
+	Checked   int
+	Drift     []RoleDrift
+	Fixed     int
+	Failed    int
+	Untracked int
+}
+
+// Reconcile walks every guild member and compares their managed roles with the
+// users table. Tracked members are fixed unless dryRun is set; members with no
+// database row are only reported, since their roles may have been granted by hand.
+func (b *Bot) Reconcile(dryRun bool) (*ReconcileReport, error) {
+	if !b.reconcileMu.TryLock() {
+		return nil, errReconcileRunning
+	}
+	defer b.reconcileMu.Unlock()
+
+	users, err := b.db.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+
+	byDiscordID := make(map[string]*User, len(users))
+	for n := range users {
+		byDiscordID[users[n].DiscordID] = &users[n]
+	}
+
+	report := &ReconcileReport{DryRun: dryRun}
+	after := ""
+	for {
+		members, err := b.session.GuildMembers(b.config().Discord.GuildID, after, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list guild members: %w", err)
+		}
+
+		for _, member := range members {
+			if member.User.Bot {
+				continue
+			}
+			report.Checked++
+
+			user := byDiscordID[member.User.ID]
+			missing, extra := b.roleDrift(user, member.Roles)
+			if len(missing) == 0 && len(extra) == 0 {
+				continue
+			}
+
+			drift := RoleDrift{
+				UserID:   member.User.ID,
+				Username: member.User.Username,
+				Missing:  missing,
+				Extra:    extra,
+				Tracked:  user != nil,
+			}
+
+			if !drift.Tracked {
+				report.Untracked++
+			} else if !dryRun {
+				drift.Error = b.fixRoleDrift(drift)
+				if drift.Error != nil {
+					report.Failed++
+				} else {
+					report.Fixed++
+				}
+			}
+
+			report.Drift = append(report.Drift, drift)
+		}
+
+		if len(members) < 1000 {
+			break
+		}
+		after = members[len(members)-1].User.ID
+	}
+
+	return report, nil
+}
+
+func (b *Bot) fixRoleDrift(drift RoleDrift) error {
+	var failures []string
+
+	for _, roleID := range drift.Missing {
+		if err := b.AssignRole(drift.UserID, roleID); err != nil {
+			LogError("Role sync: error assigning role %s to %s: %v", roleID, drift.Username, err)
+			failures = append(failures, roleID)
+		} else {
+			LogDebug("Role sync: assigned role %s to %s", roleID, drift.Username)
+		}
+	}
+
+	for _, roleID := range drift.Extra {
+		if err := b.session.GuildMemberRoleRemove(b.config().Discord.GuildID, drift.UserID, roleID); err != nil {
+			LogError("Role sync: error removing role %s from %s: %v", roleID, drift.Username, err)
+			failures = append(failures, roleID)
+		} else {
+			LogDebug("Role sync: removed role %s from %s", roleID, drift.Username)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to update roles: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// Summary returns a one-line description of the report for logs
+func (r *ReconcileReport) Summary() string {
+	summary := fmt.Sprintf("checked %d members, %d drifted, %d untracked", r.Checked, len(r.Drift), r.Untracked)
+	if r.DryRun {
+		return summary + " (dry run)"
+	}
+	return summary + fmt.Sprintf(", %d fixed, %d failed", r.Fixed, r.Failed)
+}
+
+// Embed renders the report for Discord
+func (r *ReconcileReport) Embed() *discordgo.MessageEmbed {
+	title := "🔄 Role Sync"
+	if r.DryRun {
+		title += " (Dry Run)"
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Members Checked", Value: fmt.Sprintf("%d", r.Checked), Inline: true},
+		{Name: "Drifted", Value: fmt.Sprintf("%d", len(r.Drift)), Inline: true},
+		{Name: "Untracked", Value: fmt.Sprintf("%d", r.Untracked), Inline: true},
+	}
+	if !r.DryRun {
+		fields = append(fields,
+			&discordgo.MessageEmbedField{Name: "Fixed", Value: fmt.Sprintf("%d", r.Fixed), Inline: true},
+			&discordgo.MessageEmbedField{Name: "Failed", Value: fmt.Sprintf("%d", r.Failed), Inline: true},
+		)
+	}
+
+	var description strings.Builder
+	if len(r.Drift) == 0 {
+		description.WriteString("✅ All managed roles match the database.")
+	}
+	for _, drift := range r.Drift {
+		line := fmt.Sprintf("<@%s>", drift.UserID)
+		if !drift.Tracked {
+			line += " *(not in database, left unchanged)*"
+		} else if drift.Error != nil {
+			line += " ❌"
+		}
+		if len(drift.Missing) > 0 {
+			line += " • missing " + formatRoleMentions(drift.Missing)
+		}
+		if len(drift.Extra) > 0 {
+			line += " • extra " + formatRoleMentions(drift.Extra)
+		}
+		line += "\n"
+
+		// Leave room for the overflow note
+		if description.Len()+len(line) > 3900 {
+			description.WriteString("…and more. See the logs for the full list.")
+			break
+		}
+		description.WriteString(line)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description.String(),
+		Color:       0x667eea,
+		Fields:      fields,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (b *Bot) handleSync(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	dryRun := false
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "dry_run" {
+			dryRun = opt.BoolValue()
+		}
+	}
+
+	LogInfo("Moderator %s started role sync (dry run: %t)", i.Member.User.Username, dryRun)
+
+	// Walking the member list can take longer than Discord's 3 second response window
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	report, err := b.Reconcile(dryRun)
+	if err != nil {
+		message := "❌ Role sync failed. Check the logs for details."
+		if err == errReconcileRunning {
+			message = "⏳ A role sync is already running. Please try again shortly."
+		} else {
+			LogError("Role sync failed: %v", err)
+		}
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &message})
+		return
+	}
+
+	LogSuccess("Role sync by %s: %s", i.Member.User.Username, report.Summary())
+
+	if !dryRun {
+		b.audit(i, AuditActionSync, "", "all members", "", fmt.Sprintf("%d drifted", len(report.Drift)), fmt.Sprintf("%d fixed, %d failed", report.Fixed, report.Failed))
+	}
+
+	embeds := []*discordgo.MessageEmbed{report.Embed()}
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Embeds: &embeds})
+}
+
+// RoleSyncer runs Reconcile on a schedule while role_sync.enabled is set
+type RoleSyncer struct {
+	configs *ConfigStore
+	bot     *Bot
+	reset   chan struct{} // Signalled when role_sync.interval_minutes changes
+	stop    chan struct{}
+}
+
+func NewRoleSyncer(configs *ConfigStore, bot *Bot) *RoleSyncer {
+	return &RoleSyncer{
+		configs: configs,
+		bot:     bot,
+		reset:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs scheduled role syncs in the background until Stop is called
+func (rs *RoleSyncer) Start() {
+	go func() {
+		timer := time.NewTimer(rs.config().RoleSyncInterval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				// role_sync.enabled is re-read each round so config reloads take effect
+				if rs.config().RoleSync.Enabled {
+					rs.run()
+				}
+				timer.Reset(rs.config().RoleSyncInterval())
+			case <-rs.reset:
+				// Restart the wait so a new interval applies now, not after the old one runs out
+				timer.Reset(rs.config().RoleSyncInterval())
+			case <-rs.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (rs *RoleSyncer) config() *Config {
+	return rs.configs.Get()
+}
+
+// onConfigReload restarts the wait for the next sync when role_sync.interval_minutes changes
+func (rs *RoleSyncer) onConfigReload(old, new *Config) {
+	if old.RoleSyncInterval() == new.RoleSyncInterval() {
+		return
+	}
+	LogInfo("Role sync interval changed to %s", new.RoleSyncInterval())
+	select {
+	case rs.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (rs *RoleSyncer) Stop() {
+	close(rs.stop)
+}
+
+func (rs *RoleSyncer) run() {
+	report, err := rs.bot.Reconcile(rs.config().RoleSync.DryRun)
+	if err != nil {
+		if err != errReconcileRunning {
+			LogError("Scheduled role sync failed: %v", err)
+		}
+		return
+	}
+
+	if len(report.Drift) == 0 {
+		LogDebug("Scheduled role sync: %s", report.Summary())
+		return
+	}
+
+	LogWarn("Scheduled role sync found drift: %s", report.Summary())
+	for _, drift := range report.Drift {
+		LogInfo("Role drift for %s (tracked: %t): missing=%v extra=%v", drift.Username, drift.Tracked, drift.Missing, drift.Extra)
+	}
+
+	channelID := rs.config().Discord.AuditChannelID
+	if channelID != "" && rs.config().AuditEventEnabled(EventRoleSync) {
+		if _, err := rs.bot.session.ChannelMessageSendEmbed(channelID, report.Embed()); err != nil {
+			LogError("Error posting role sync report to audit channel: %v", err)
+		}
+	}
+}
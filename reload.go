@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 5 * time.Second
+
+// ConfigStore holds the live configuration. Readers call Get for every use so
+// that a reload takes effect immediately; the pointer is swapped atomically and
+// a loaded Config is never modified afterwards.
+type ConfigStore struct {
+	path     string
+	current  atomic.Pointer[Config]
+	mu       sync.Mutex // Serialises reloads
+	onReload []func(old, new *Config)
+	modTime  time.Time
+	size     int64
+	stop     chan struct{}
+}
+
+func NewConfigStore(path string, config *Config) *ConfigStore {
+	store := &ConfigStore{
+		path: path,
+		stop: make(chan struct{}),
+	}
+	store.current.Store(config)
+	if info, err := os.Stat(path); err == nil {
+		store.modTime, store.size = info.ModTime(), info.Size()
+	}
+	return store
+}
+
+// Get returns the current configuration
+func (cs *ConfigStore) Get() *Config {
+	return cs.current.Load()
+}
+
+// OnReload registers a callback that runs after a new configuration is swapped in
+func (cs *ConfigStore) OnReload(fn func(old, new *Config)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.onReload = append(cs.onReload, fn)
+}
+
+// Reload reads and validates the config file and swaps it in. If the file
+// can't be loaded or is invalid the current configuration is kept. Callbacks
+// run after the lock is released, so a slow one doesn't hold up the watcher.
+func (cs *ConfigStore) Reload() error {
+	old, config, callbacks, err := cs.swap()
+	if err != nil || config == nil {
+		return err
+	}
+
+	for _, fn := range callbacks {
+		fn(old, config)
+	}
+	return nil
+}
+
+// swap loads the config file and stores it if it changed, returning the old
+// and new configs and the callbacks to run. The new config is nil when the
+// file is unchanged.
+func (cs *ConfigStore) swap() (old, config *Config, callbacks []func(old, new *Config), err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if info, err := os.Stat(cs.path); err == nil {
+		cs.modTime, cs.size = info.ModTime(), info.Size()
+	}
+
+	config, err = LoadConfig(cs.path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load %s: %w", cs.path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid %s: %w", cs.path, err)
+	}
+
+	old = cs.current.Load()
+	if reflect.DeepEqual(old, config) {
+		LogDebug("Config reload: %s unchanged", cs.path)
+		return nil, nil, nil, nil
+	}
+
+	for _, field := range restartRequiredChanges(old, config) {
+		LogWarn("Config reload: %s changed, restart Heimdall to apply it", field)
+	}
+
+	cs.current.Store(config)
+	LogSuccess("Configuration reloaded from %s", cs.path)
+	return old, config, slices.Clone(cs.onReload), nil
+}
+
+// Watch reloads the config whenever the file's modification time or size
+// changes, until Stop is called. Polling avoids a dependency on inotify and
+// copes with editors that replace the file rather than writing in place.
+func (cs *ConfigStore) Watch() {
+	go func() {
+		ticker := time.NewTicker(configWatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if cs.changedOnDisk() {
+					if err := cs.Reload(); err != nil {
+						LogError("Config reload failed, keeping previous configuration: %v", err)
+					}
+				}
+			case <-cs.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (cs *ConfigStore) Stop() {
+	close(cs.stop)
+}
+
+func (cs *ConfigStore) changedOnDisk() bool {
+	info, err := os.Stat(cs.path)
+	if err != nil {
+		// Mid-replace or deleted; try again on the next tick
+		return false
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return !info.ModTime().Equal(cs.modTime) || info.Size() != cs.size
+}
+
+// restartRequiredChanges lists settings that only take effect at startup
+func restartRequiredChanges(old, new *Config) []string {
+	var changed []string
+	if old.Discord.Token != new.Discord.Token {
+		changed = append(changed, "discord.token")
+	}
+	if old.Discord.GuildID != new.Discord.GuildID {
+		changed = append(changed, "discord.guild_id")
+	}
+	if old.Server.Port != new.Server.Port {
+		changed = append(changed, "server.port")
+	}
+	if old.Server.LogLevel != new.Server.LogLevel {
+		changed = append(changed, "server.log_level")
+	}
+	return changed
+}
+
+// commandsChanged reports whether a reload affects the registered slash commands
+func commandsChanged(old, new *Config) bool {
+	if old.Features.EnableTeamSelection != new.Features.EnableTeamSelection ||
+		old.TeamChanges.Enabled != new.TeamChanges.Enabled ||
+		old.Reverification.Enabled != new.Reverification.Enabled {
+		return true
+	}
+	oldTeams, newTeams := sortedKeys(old.Teams), sortedKeys(new.Teams)
+	return !reflect.DeepEqual(oldTeams, newTeams)
+}
+
+// sortedKeys returns a map's keys in order, for stable output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// onConfigReload re-registers slash commands when the team choices change and
+// recomputes canonical emails when the normalization settings change
+func (b *Bot) onConfigReload(old, new *Config) {
+	if emailNormalizationChanged(old, new) {
+		b.recanonicalizeEmails()
+	}
+
+	if !commandsChanged(old, new) {
+		return
+	}
+
+	if err := b.registerCommands(); err != nil {
+		LogError("Error re-registering commands after config reload: %v", err)
+		return
+	}
+	LogInfo("Slash commands re-registered with %d teams", len(new.Teams))
+}
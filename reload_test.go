@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeTestConfig saves a config to a YAML file for Reload to pick up
+func writeTestConfig(t *testing.T, path string, config *Config) {
+	t.Helper()
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadRunsCallbacksWithoutLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := validConfig()
+	writeTestConfig(t, path, config)
+	store := NewConfigStore(path, config)
+
+	var got *Config
+	store.OnReload(func(old, new *Config) {
+		// Both take the store's lock, so this deadlocks if callbacks run under it
+		store.changedOnDisk()
+		store.OnReload(func(old, new *Config) {})
+		got = new
+	})
+
+	changed := validConfig()
+	changed.RoleSync.IntervalMinutes = 15
+	writeTestConfig(t, path, changed)
+
+	done := make(chan error, 1)
+	go func() { done <- store.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload didn't return; the callback is blocked on the store's lock")
+	}
+
+	if got == nil || got.RoleSync.IntervalMinutes != 15 {
+		t.Errorf("callback got %+v, want the reloaded config", got)
+	}
+	if store.Get() != got {
+		t.Error("Get doesn't return the config the callbacks were given")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// restrictionCheckInterval is how often expired restrictions are looked for
+	restrictionCheckInterval = time.Minute
+
+	// maxRestrictionDuration caps the duration option on /heimdall-restrict
+	maxRestrictionDuration = 365 * 24 * time.Hour
+)
+
+// restrictionUnits are the suffixes accepted by parseRestrictionDuration
+var restrictionUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseRestrictionDuration parses durations like "30m", "12h", "7d", "2w" or "1d12h"
+func parseRestrictionDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, fmt.Errorf("duration is empty")
+	}
+
+	var total time.Duration
+	rest := value
+	for rest != "" {
+		n := 0
+		for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		if n == 0 || n == len(rest) {
+			return 0, fmt.Errorf("%q is not a duration like 12h, 7d or 2w", value)
+		}
+		unit, ok := restrictionUnits[rest[n]]
+		if !ok {
+			return 0, fmt.Errorf("%q has an unknown unit %q (use m, h, d or w)", value, rest[n])
+		}
+		amount, err := strconv.Atoi(rest[:n])
+		if err != nil || time.Duration(amount) > maxRestrictionDuration/unit {
+			return 0, fmt.Errorf("%q is longer than 365 days", value)
+		}
+		total += time.Duration(amount) * unit
+		rest = rest[n+1:]
+	}
+
+	if total <= 0 {
+		return 0, fmt.Errorf("%q must be longer than zero", value)
+	}
+	if total > maxRestrictionDuration {
+		return 0, fmt.Errorf("%q is longer than 365 days", value)
+	}
+	return total, nil
+}
+
+// GetExpiredRestrictions returns restricted users whose restriction has run out
+func (d *Database) GetExpiredRestrictions() ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE COALESCE(unverified, 0) = 1 AND restricted_until IS NOT NULL AND restricted_until <= datetime('now')
+		ORDER BY restricted_until`
+	return d.queryUsers(query)
+}
+
+// LiftExpiredRestriction restores a user whose restriction has run out. It
+// returns false if a moderator unrestricted them or changed the restriction first.
+func (d *Database) LiftExpiredRestriction(discordID string) (bool, error) {
+	query := `
+		UPDATE users
+		SET verified = TRUE, unverified = FALSE, verified_at = CURRENT_TIMESTAMP, restricted_until = NULL, reverify_requested_at = NULL, reverify_lapsed = FALSE
+		WHERE discord_id = ? AND COALESCE(unverified, 0) = 1
+		  AND restricted_until IS NOT NULL AND restricted_until <= datetime('now')
+	`
+	result, err := d.db.Exec(query, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RestrictionScheduler lifts time-limited restrictions once they run out.
+// Expiry times live in the database, so restrictions that lapse while the bot
+// is down are lifted on the first check after it starts.
+type RestrictionScheduler struct {
+	db   *Database
+	bot  *Bot
+	stop chan struct{}
+}
+
+func NewRestrictionScheduler(db *Database, bot *Bot) *RestrictionScheduler {
+	return &RestrictionScheduler{
+		db:   db,
+		bot:  bot,
+		stop: make(chan struct{}),
+	}
+}
+
+// Start checks for expired restrictions in the background until Stop is called
+func (rs *RestrictionScheduler) Start() {
+	go func() {
+		rs.liftExpired()
+
+		ticker := time.NewTicker(restrictionCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rs.liftExpired()
+			case <-rs.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (rs *RestrictionScheduler) Stop() {
+	close(rs.stop)
+}
+
+func (rs *RestrictionScheduler) liftExpired() {
+	users, err := rs.db.GetExpiredRestrictions()
+	if err != nil {
+		LogError("Error finding expired restrictions: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		lifted, err := rs.db.LiftExpiredRestriction(user.DiscordID)
+		if err != nil {
+			LogError("Error lifting expired restriction for %s: %v", user.DiscordUsername, err)
+			continue
+		}
+		if !lifted {
+			continue
+		}
+
+		if err := rs.bot.grantVerifiedRoles(&user, user.Teams); err != nil {
+			LogWarn("Restriction on %s expired but some team roles couldn't be restored: %v", user.DiscordUsername, err)
+		}
+
+		rs.bot.auditAutomatic(AuditActionUnrestrict, user.DiscordID, user.DiscordUsername, "Restriction expired", auditState(&user), auditState(&User{Verified: true, Teams: user.Teams}))
+		rs.bot.events.Publish(Event{
+			Type:     EventUnrestrict,
+			UserID:   user.DiscordID,
+			Username: user.DiscordUsername,
+			Team:     user.TeamNames(),
+			Reason:   "Restriction expired",
+		})
+
+		LogSuccess("Restriction on %s expired; access restored", user.DiscordUsername)
+		dm := "✅ Your restriction has ended and your server access has been restored."
+		if len(user.Teams) > 0 {
+			dm += fmt.Sprintf(" Welcome back to %s.", teamPhrase(user.Teams))
+		}
+		rs.bot.SendDM(user.DiscordID, dm)
+	}
+}
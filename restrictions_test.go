@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRestrictionDuration(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "30m", want: 30 * time.Minute},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "7d", want: 7 * day},
+		{value: "2w", want: 14 * day},
+		{value: "1d12h", want: 36 * time.Hour},
+		{value: "1w2d3h4m", want: 9*day + 3*time.Hour + 4*time.Minute},
+		{value: "365d", want: 365 * day},
+		{value: "366d", wantErr: true},
+		{value: "53w", wantErr: true},
+		{value: "364d2d", wantErr: true},
+		{value: "99999999999999999999d", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "0d", wantErr: true},
+		{value: "0h0m", wantErr: true},
+		{value: "12", wantErr: true},
+		{value: "h", wantErr: true},
+		{value: "12s", wantErr: true},
+		{value: "1.5d", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "7 d", wantErr: true},
+		{value: "7D", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRestrictionDuration(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseRestrictionDuration(%q) = %s, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRestrictionDuration(%q) returned error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseRestrictionDuration(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
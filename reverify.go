@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// reverificationCheckInterval is how often members are checked for coming due or lapsing
+	reverificationCheckInterval = time.Hour
+
+	// defaultReverificationReportDays is the window /heimdall-reverification looks ahead by default
+	defaultReverificationReportDays = 30
+)
+
+// ReverificationDue returns when a verified member must next confirm their email
+func (u *User) ReverificationDue(interval time.Duration) time.Time {
+	verifiedAt := u.CreatedAt
+	if u.VerifiedAt != nil {
+		verifiedAt = *u.VerifiedAt
+	}
+	return verifiedAt.Add(interval)
+}
+
+// sqliteAgo renders a duration as a datetime('now', ?) modifier
+func sqliteAgo(d time.Duration) string {
+	return fmt.Sprintf("%+d seconds", -int64(d.Seconds()))
+}
+
+// GetUsersDueForReverification returns verified members whose last
+// verification is older than interval and who haven't been asked yet
+func (d *Database) GetUsersDueForReverification(interval time.Duration) ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE verified = TRUE AND COALESCE(unverified, 0) = 0 AND reverify_requested_at IS NULL
+		  AND COALESCE(verified_at, created_at) < datetime('now', ?)
+		ORDER BY verified_at`
+	return d.queryUsers(query, sqliteAgo(interval))
+}
+
+// RequestReverification gives a verified member a new link code and starts
+// their grace period. It returns false if they were already asked or are no longer verified.
+func (d *Database) RequestReverification(discordID, verificationCode string) (bool, error) {
+	query := `
+		UPDATE users
+		SET verification_code = ?, reverify_requested_at = CURRENT_TIMESTAMP
+		WHERE discord_id = ? AND verified = TRUE AND reverify_requested_at IS NULL
+	`
+	result, err := d.db.Exec(query, verificationCode, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CancelReverificationRequest clears a request whose email couldn't be sent, so the next check tries again
+func (d *Database) CancelReverificationRequest(discordID string) error {
+	_, err := d.db.Exec(`UPDATE users SET reverify_requested_at = NULL WHERE discord_id = ?`, discordID)
+	return err
+}
+
+// ConfirmReverification restarts a member's verification interval, restoring
+// them if they had lapsed. It returns false if they weren't waiting to confirm.
+func (d *Database) ConfirmReverification(discordID string) (bool, error) {
+	query := `
+		UPDATE users
+		SET verified = TRUE, verified_at = CURRENT_TIMESTAMP, reverify_requested_at = NULL, reverify_lapsed = FALSE
+		WHERE discord_id = ?
+		  AND ((verified = TRUE AND reverify_requested_at IS NOT NULL) OR COALESCE(reverify_lapsed, 0) = 1)
+	`
+	result, err := d.db.Exec(query, discordID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetLapsedReverifications returns members who were asked to confirm more than grace ago and haven't
+func (d *Database) GetLapsedReverifications(grace time.Duration) ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE verified = TRUE AND reverify_requested_at < datetime('now', ?)
+		ORDER BY reverify_requested_at`
+	return d.queryUsers(query, sqliteAgo(grace))
+}
+
+// DemoteLapsedUser pauses the access of a member who didn't confirm in time.
+// They keep their record and teams, aren't treated as pending, and get access
+// back by confirming with their link. It returns false if they confirmed or
+// changed state in the meantime.
+func (d *Database) DemoteLapsedUser(discordID string, grace time.Duration) (bool, error) {
+	query := `
+		UPDATE users
+		SET verified = FALSE, reverify_requested_at = NULL, reverify_lapsed = TRUE
+		WHERE discord_id = ? AND verified = TRUE AND reverify_requested_at < datetime('now', ?)
+	`
+	result, err := d.db.Exec(query, discordID, sqliteAgo(grace))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RegenerateReverificationCode replaces a lapsed member's link code and counts
+// the resend against their 24 hour resend window
+func (d *Database) RegenerateReverificationCode(discordID, verificationCode string) error {
+	query := `
+		UPDATE users
+		SET verification_code = ?,` + resendWindowUpdate + `
+		WHERE discord_id = ? AND COALESCE(reverify_lapsed, 0) = 1
+	`
+	return d.execPendingUpdate(query, verificationCode, discordID)
+}
+
+// GetUpcomingReverifications returns verified members who are waiting to
+// confirm or will come due within the next window
+func (d *Database) GetUpcomingReverifications(interval, window time.Duration) ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE verified = TRUE AND COALESCE(unverified, 0) = 0
+		  AND (reverify_requested_at IS NOT NULL OR COALESCE(verified_at, created_at) < datetime('now', ?))
+		ORDER BY COALESCE(verified_at, created_at)`
+	return d.queryUsers(query, sqliteAgo(interval-window))
+}
+
+// Reverifier asks verified members to confirm their email again once
+// reverification.interval_days has passed, and demotes those who don't
+// confirm within the grace period
+type Reverifier struct {
+	configs *ConfigStore
+	db      *Database
+	bot     *Bot
+	stop    chan struct{}
+}
+
+func NewReverifier(configs *ConfigStore, db *Database, bot *Bot) *Reverifier {
+	return &Reverifier{
+		configs: configs,
+		db:      db,
+		bot:     bot,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs reverification checks in the background until Stop is called
+func (r *Reverifier) Start() {
+	go func() {
+		r.run()
+
+		ticker := time.NewTicker(reverificationCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.run()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reverifier) config() *Config {
+	return r.configs.Get()
+}
+
+func (r *Reverifier) Stop() {
+	close(r.stop)
+}
+
+func (r *Reverifier) run() {
+	if !r.config().Reverification.Enabled {
+		return
+	}
+	r.demoteLapsed()
+	r.requestDue()
+}
+
+func (r *Reverifier) requestDue() {
+	users, err := r.db.GetUsersDueForReverification(r.config().ReverificationInterval())
+	if err != nil {
+		LogError("Error finding members due for reverification: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		code, err := generateVerificationCode()
+		if err != nil {
+			LogError("Error generating reverification code for %s: %v", user.DiscordUsername, err)
+			continue
+		}
+
+		requested, err := r.db.RequestReverification(user.DiscordID, code)
+		if err != nil {
+			LogError("Error requesting reverification for %s: %v", user.DiscordUsername, err)
+			continue
+		}
+		if !requested {
+			continue
+		}
+
+		deadline := time.Now().Add(r.config().ReverificationGrace())
+		if err := r.bot.emailService.SendReverificationEmail(user.Email, code, user.DiscordUsername, &deadline); err != nil {
+			LogError("Error sending reverification email to %s: %v", user.Email, err)
+			if err := r.db.CancelReverificationRequest(user.DiscordID); err != nil {
+				LogError("Error cancelling reverification request for %s: %v", user.DiscordUsername, err)
+			}
+			continue
+		}
+
+		LogInfo("Asked %s to confirm their email again (%s) by %s", user.DiscordUsername, user.Email, deadline.Format(time.RFC3339))
+		r.bot.SendDM(user.DiscordID, fmt.Sprintf("🔄 It's time to confirm your work email again. I've sent a link to **%s**. Please click it by %s to keep your access to the server.", user.Email, discordTimestamp(deadline)))
+	}
+}
+
+func (r *Reverifier) demoteLapsed() {
+	users, err := r.db.GetLapsedReverifications(r.config().ReverificationGrace())
+	if err != nil {
+		LogError("Error finding lapsed reverifications: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		demoted, err := r.db.DemoteLapsedUser(user.DiscordID, r.config().ReverificationGrace())
+		if err != nil {
+			LogError("Error demoting %s after missed reverification: %v", user.DiscordUsername, err)
+			continue
+		}
+		if !demoted {
+			continue
+		}
+
+		r.bot.revokeVerifiedRoles(&user)
+		r.bot.auditAutomatic(AuditActionReverifyLapsed, user.DiscordID, user.DiscordUsername, "Email not confirmed within the grace period", auditState(&user), auditState(&User{ReverifyLapsed: true, Teams: user.Teams}))
+
+		LogWarn("%s didn't confirm their email in time; access paused", user.DiscordUsername)
+		r.bot.SendDM(user.DiscordID, fmt.Sprintf("⌛ You didn't confirm your work email in time, so your server access has been paused. Click the link in the email sent to **%s** to get it back, or reply **resend** for a new link.", user.Email))
+	}
+}
+
+// confirmReverification handles a verified or lapsed member submitting their reverification link
+func (ws *WebServer) confirmReverification(w http.ResponseWriter, user *User) {
+	confirmed, err := ws.db.ConfirmReverification(user.DiscordID)
+	if err != nil {
+		LogError("Error confirming reverification for %s: %v", user.DiscordUsername, err)
+		http.Error(w, "Failed to confirm your email", http.StatusInternalServerError)
+		return
+	}
+	message := "✅ Email confirmed! Your access continues. You can now close this page and return to Discord."
+	switch {
+	case confirmed && user.ReverifyLapsed:
+		if err := ws.bot.grantVerifiedRoles(user, user.Teams); err != nil {
+			LogWarn("%s confirmed their email but some team roles couldn't be restored: %v", user.DiscordUsername, err)
+		}
+		ws.events.Publish(Event{
+			Type:     EventVerification,
+			UserID:   user.DiscordID,
+			Username: user.DiscordUsername,
+			Email:    user.Email,
+			Team:     user.TeamNames(),
+			Reason:   "Confirmed email after reverification lapsed",
+		})
+		LogSuccess("%s confirmed their email after lapsing (%s); access restored", user.DiscordUsername, user.Email)
+		ws.bot.SendDM(user.DiscordID, "✅ Thanks for confirming your work email. Your server access has been restored.")
+		message = "✅ Email confirmed! Your access has been restored. You can now close this page and return to Discord."
+	case confirmed:
+		LogSuccess("%s confirmed their email again (%s)", user.DiscordUsername, user.Email)
+		ws.bot.SendDM(user.DiscordID, "✅ Thanks for confirming your work email. Your access to the server continues.")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "reverified",
+		"message": message,
+	})
+}
+
+func (b *Bot) handleReverificationReport(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to use this command.")
+		return
+	}
+
+	days := defaultReverificationReportDays
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "days" {
+			days = int(opt.IntValue())
+		}
+	}
+	if days < 0 {
+		b.respondEphemeral(s, i, "❌ Days must not be negative.")
+		return
+	}
+
+	LogDebug("Moderator %s requested reverification report (%d days)", i.Member.User.Username, days)
+
+	interval := b.config().ReverificationInterval()
+	window := time.Duration(days) * 24 * time.Hour
+	users, err := b.db.GetUpcomingReverifications(interval, window)
+	if err != nil {
+		LogError("Error getting reverification report: %v", err)
+		b.respondEphemeral(s, i, "❌ Database error occurred.")
+		return
+	}
+
+	var waiting, upcoming strings.Builder
+	var waitingCount, upcomingCount int
+	for _, user := range users {
+		if user.ReverifyAskedAt != nil {
+			waitingCount++
+			deadline := user.ReverifyAskedAt.Add(b.config().ReverificationGrace())
+			waiting.WriteString(fmt.Sprintf("<@%s> (%s) - by %s\n", user.DiscordID, user.Email, discordTimestamp(deadline)))
+		} else {
+			upcomingCount++
+			upcoming.WriteString(fmt.Sprintf("<@%s> (%s) - %s\n", user.DiscordID, user.Email, discordTimestamp(user.ReverificationDue(interval))))
+		}
+	}
+
+	waitingValue, upcomingValue := waiting.String(), upcoming.String()
+	if waitingValue == "" {
+		waitingValue = "Nobody"
+	}
+	if upcomingValue == "" {
+		upcomingValue = "Nobody"
+	}
+
+	description := fmt.Sprintf("Members confirm their email every %s and have %s to respond.", formatDays(interval), formatDays(b.config().ReverificationGrace()))
+	if !b.config().Reverification.Enabled {
+		description += "\n⚠️ Reverification is currently disabled, so nobody will be asked."
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "🔄 Reverification Report",
+		Description: description,
+		Color:       0x667eea,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: fmt.Sprintf("Waiting to Confirm (%d)", waitingCount), Value: truncate(waitingValue, 1024)},
+			{Name: fmt.Sprintf("Due in the Next %d Days (%d)", days, upcomingCount), Value: truncate(upcomingValue, 1024)},
+		},
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// formatDays renders a reverification period in whole days, e.g. "180 days" or "1 day"
+func formatDays(d time.Duration) string {
+	days := int(math.Round(d.Hours() / 24))
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testReverifyInterval = 180 * 24 * time.Hour
+	testReverifyGrace    = 7 * 24 * time.Hour
+	testReverifyWindow   = 30 * 24 * time.Hour
+)
+
+// newReverificationTestDatabase returns a migrated database holding one member in each reverification state
+func newReverificationTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := openTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	members := []struct {
+		id            string
+		verifiedDays  int
+		requestedDays int // Days since they were asked to confirm; -1 if they haven't been
+		restricted    bool
+	}{
+		{"fresh", 10, -1, false},
+		{"coming-due", 160, -1, false},
+		{"due", 200, -1, false},
+		{"in-grace", 200, 3, false},
+		{"lapsed", 200, 10, false},
+		{"restricted", 200, -1, true},
+	}
+	for _, m := range members {
+		if err := db.CreateUser(m.id, m.id, m.id+"@company.com", m.id+"@company.com", "code-"+m.id); err != nil {
+			t.Fatalf("CreateUser(%s): %v", m.id, err)
+		}
+		_, err := db.db.Exec(`UPDATE users SET verified = TRUE, unverified = ?, verified_at = datetime('now', ?) WHERE discord_id = ?`,
+			m.restricted, fmt.Sprintf("-%d days", m.verifiedDays), m.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.requestedDays >= 0 {
+			_, err := db.db.Exec(`UPDATE users SET reverify_requested_at = datetime('now', ?) WHERE discord_id = ?`,
+				fmt.Sprintf("-%d days", m.requestedDays), m.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+// userIDs returns a function that lists the sorted Discord IDs from a user query, failing the test on error
+func userIDs(t *testing.T) func(users []User, err error) []string {
+	return func(users []User, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(users))
+		for n, user := range users {
+			ids[n] = user.DiscordID
+		}
+		slices.Sort(ids)
+		return ids
+	}
+}
+
+func TestReverificationQueries(t *testing.T) {
+	db := newReverificationTestDatabase(t)
+	ids := userIDs(t)
+
+	due := ids(db.GetUsersDueForReverification(testReverifyInterval))
+	if want := []string{"due"}; !slices.Equal(due, want) {
+		t.Errorf("due for reverification = %v, want %v", due, want)
+	}
+
+	lapsed := ids(db.GetLapsedReverifications(testReverifyGrace))
+	if want := []string{"lapsed"}; !slices.Equal(lapsed, want) {
+		t.Errorf("lapsed = %v, want %v", lapsed, want)
+	}
+
+	// The report shows members coming due within the window and everyone waiting to confirm
+	upcoming := ids(db.GetUpcomingReverifications(testReverifyInterval, testReverifyWindow))
+	if want := []string{"coming-due", "due", "in-grace", "lapsed"}; !slices.Equal(upcoming, want) {
+		t.Errorf("upcoming = %v, want %v", upcoming, want)
+	}
+}
+
+func TestReverificationRequestAndConfirm(t *testing.T) {
+	db := newReverificationTestDatabase(t)
+	ids := userIDs(t)
+
+	requested, err := db.RequestReverification("due", "new-code")
+	if err != nil || !requested {
+		t.Fatalf("RequestReverification(due) = %t, %v, want true", requested, err)
+	}
+	if requested, _ := db.RequestReverification("due", "newer-code"); requested {
+		t.Error("a member already asked to confirm was asked again")
+	}
+	if due := ids(db.GetUsersDueForReverification(testReverifyInterval)); len(due) != 0 {
+		t.Errorf("due after requesting = %v, want none", due)
+	}
+	if lapsed := ids(db.GetLapsedReverifications(testReverifyGrace)); slices.Contains(lapsed, "due") {
+		t.Error("a member asked just now is already lapsed")
+	}
+
+	confirmed, err := db.ConfirmReverification("in-grace")
+	if err != nil || !confirmed {
+		t.Fatalf("ConfirmReverification(in-grace) = %t, %v, want true", confirmed, err)
+	}
+	user, err := db.GetUserByDiscordID("in-grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ReverifyAskedAt != nil || time.Since(user.ReverificationDue(testReverifyInterval)) > 0 {
+		t.Errorf("confirming should restart the interval: %+v", user)
+	}
+
+	if confirmed, _ := db.ConfirmReverification("fresh"); confirmed {
+		t.Error("a member who wasn't asked to confirm was confirmed")
+	}
+}
+
+func TestDemoteLapsedUser(t *testing.T) {
+	db := newReverificationTestDatabase(t)
+	ids := userIDs(t)
+
+	if demoted, _ := db.DemoteLapsedUser("in-grace", testReverifyGrace); demoted {
+		t.Error("a member still in their grace period was demoted")
+	}
+
+	demoted, err := db.DemoteLapsedUser("lapsed", testReverifyGrace)
+	if err != nil || !demoted {
+		t.Fatalf("DemoteLapsedUser(lapsed) = %t, %v, want true", demoted, err)
+	}
+	user, err := db.GetUserByDiscordID("lapsed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Verified || !user.ReverifyLapsed || user.IsPending() {
+		t.Errorf("lapsed member should lose access without becoming pending: %+v", user)
+	}
+	if lapsed := ids(db.GetLapsedReverifications(testReverifyGrace)); len(lapsed) != 0 {
+		t.Errorf("lapsed after demoting = %v, want none", lapsed)
+	}
+
+	// Lapsed members are kept on record, however long ago their link was sent
+	if _, err := db.db.Exec(`UPDATE users SET code_issued_at = datetime('now', '-30 days') WHERE discord_id = 'lapsed'`); err != nil {
+		t.Fatal(err)
+	}
+	if expired := ids(db.GetExpiredPendingUsers(24 * time.Hour)); slices.Contains(expired, "lapsed") {
+		t.Error("lapsed member is treated as an expired pending user")
+	}
+	if deleted, err := db.DeletePendingUser("lapsed"); err != nil || deleted {
+		t.Errorf("DeletePendingUser(lapsed) = %t, %v, want false", deleted, err)
+	}
+
+	confirmed, err := db.ConfirmReverification("lapsed")
+	if err != nil || !confirmed {
+		t.Fatalf("ConfirmReverification(lapsed) = %t, %v, want true", confirmed, err)
+	}
+	user, err = db.GetUserByDiscordID("lapsed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Verified || user.ReverifyLapsed {
+		t.Errorf("confirming should restore a lapsed member: %+v", user)
+	}
+}
+
+func TestFormatDays(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{testReverifyInterval, "180 days"},
+		{testReverifyGrace, "7 days"},
+		{24 * time.Hour, "1 day"},
+	}
+	for _, tt := range tests {
+		if got := formatDays(tt.d); got != tt.want {
+			t.Errorf("formatDays(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
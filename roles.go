@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// managedRoleIDs returns every role Heimdall hands out: the members role, all team roles
+// and any per-domain extra roles.
+// Roles outside this set are never reported as drift.
+func (b *Bot) managedRoleIDs() map[string]bool {
+	managed := make(map[string]bool)
+	if b.config().Discord.MembersRole != "" {
+		managed[b.config().Discord.MembersRole] = true
+	}
+	for _, roleID := range b.config().Teams {
+		managed[roleID] = true
+	}
+	for _, settings := range b.config().DomainSettings {
+		for _, roleID := range settings.ExtraRoles {
+			managed[roleID] = true
+		}
+	}
+	return managed
+}
+
+// expectedRoleIDs returns the managed roles a user should hold according to the database.
+// Pending, awaiting approval, restricted and unknown (nil) users should hold none.
+func (b *Bot) expectedRoleIDs(user *User) map[string]bool {
+	expected := make(map[string]bool)
+	if user == nil || !user.Verified || user.Unverified {
+		return expected
+	}
+
+	if b.config().Discord.MembersRole != "" {
+		expected[b.config().Discord.MembersRole] = true
+	}
+	for _, team := range user.Teams {
+		if roleID, exists := b.config().Teams[team]; exists {
+			expected[roleID] = true
+		}
+	}
+	for _, roleID := range b.config().DomainSettingsFor(user.Email).ExtraRoles {
+		expected[roleID] = true
+	}
+	return expected
+}
+
+// grantVerifiedRoles gives a verified user the members role, their team roles
+// and their domain's extra roles. Failures are logged, and the first team role
+// failure is returned for callers that need to report it.
+func (b *Bot) grantVerifiedRoles(user *User, teams []string) error {
+	if b.config().Discord.MembersRole != "" {
+		if err := b.AssignRole(user.DiscordID, b.config().Discord.MembersRole); err != nil {
+			LogError("Error assigning members role to %s: %v", user.DiscordUsername, err)
+		} else {
+			LogDebug("Assigned members role to %s", user.DiscordUsername)
+		}
+	}
+
+	b.assignDomainRoles(user.DiscordID, user.Email, user.DiscordUsername)
+	return b.assignTeamRoles(user.DiscordID, teams, user.DiscordUsername)
+}
+
+// revokeVerifiedRoles takes away everything grantVerifiedRoles gives. Failures are logged.
+func (b *Bot) revokeVerifiedRoles(user *User) {
+	b.removeTeamRoles(user.DiscordID, user.Teams, user.DiscordUsername)
+
+	if b.config().Discord.MembersRole != "" {
+		if err := b.session.GuildMemberRoleRemove(b.config().Discord.GuildID, user.DiscordID, b.config().Discord.MembersRole); err != nil {
+			LogWarn("Error removing members role from %s: %v", user.DiscordUsername, err)
+		} else {
+			LogDebug("Removed members role from %s", user.DiscordUsername)
+		}
+	}
+
+	b.removeDomainRoles(user.DiscordID, user.Email, user.DiscordUsername)
+}
+
+// assignTeamRoles grants the role for each of a user's teams, returning the
+// first error so callers can tell the moderator to fix roles by hand
+func (b *Bot) assignTeamRoles(userID string, teams []string, username string) error {
+	var firstErr error
+	for _, team := range teams {
+		roleID, exists := b.config().Teams[team]
+		if !exists {
+			continue
+		}
+		if err := b.AssignRole(userID, roleID); err != nil {
+			LogError("Error assigning %s team role to %s: %v", team, username, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			LogDebug("Assigned %s team role to %s", team, username)
+		}
+	}
+	return firstErr
+}
+
+// removeTeamRoles takes away the role for each of the given teams
+func (b *Bot) removeTeamRoles(userID string, teams []string, username string) {
+	for _, team := range teams {
+		roleID, exists := b.config().Teams[team]
+		if !exists {
+			continue
+		}
+		if err := b.session.GuildMemberRoleRemove(b.config().Discord.GuildID, userID, roleID); err != nil {
+			LogWarn("Error removing %s team role from %s: %v", team, username, err)
+		} else {
+			LogDebug("Removed %s team role from %s", team, username)
+		}
+	}
+}
+
+// teamPhrase renders teams for messages: "the **A** team" or "the **A** and **B** teams"
+func teamPhrase(teams []string) string {
+	bold := make([]string, len(teams))
+	for n, team := range teams {
+		bold[n] = "**" + team + "**"
+	}
+	switch len(bold) {
+	case 0:
+		return "no team"
+	case 1:
+		return "the " + bold[0] + " team"
+	default:
+		return "the " + strings.Join(bold[:len(bold)-1], ", ") + " and " + bold[len(bold)-1] + " teams"
+	}
+}
+
+// assignDomainRoles grants the extra roles configured for a user's email domain
+func (b *Bot) assignDomainRoles(userID, email, username string) {
+	for _, roleID := range b.config().DomainSettingsFor(email).ExtraRoles {
+		if err := b.AssignRole(userID, roleID); err != nil {
+			LogError("Error assigning domain role %s to %s: %v", roleID, username, err)
+		} else {
+			LogDebug("Assigned domain role %s to %s", roleID, username)
+		}
+	}
+}
+
+// removeDomainRoles takes away the extra roles configured for a user's email domain
+func (b *Bot) removeDomainRoles(userID, email, username string) {
+	for _, roleID := range b.config().DomainSettingsFor(email).ExtraRoles {
+		if err := b.session.GuildMemberRoleRemove(b.config().Discord.GuildID, userID, roleID); err != nil {
+			LogWarn("Error removing domain role %s from %s: %v", roleID, username, err)
+		} else {
+			LogDebug("Removed domain role %s from %s", roleID, username)
+		}
+	}
+}
+
+// roleDrift compares a member's current roles with what the database says they
+// should have, returning managed roles that are missing and ones that shouldn't be there
+func (b *Bot) roleDrift(user *User, memberRoles []string) (missing, extra []string) {
+	expected := b.expectedRoleIDs(user)
+	managed := b.managedRoleIDs()
+
+	has := make(map[string]bool, len(memberRoles))
+	for _, roleID := range memberRoles {
+		has[roleID] = true
+		if managed[roleID] && !expected[roleID] {
+			extra = append(extra, roleID)
+		}
+	}
+
+	for roleID := range expected {
+		if !has[roleID] {
+			missing = append(missing, roleID)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+// formatRoleMentions renders role IDs as Discord role mentions
+func formatRoleMentions(roleIDs []string) string {
+	mentions := make([]string, len(roleIDs))
+	for n, roleID := range roleIDs {
+		mentions[n] = fmt.Sprintf("<@&%s>", roleID)
+	}
+	return strings.Join(mentions, ", ")
+}
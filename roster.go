@@ -0,0 +1,756 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// rosterCustomIDPrefix starts the custom ID of the roster report buttons;
+	// the rest is "apply:<id>" or "cancel:<id>"
+	rosterCustomIDPrefix = "heimdall-roster:"
+
+	// rosterCheckInterval is how often the roster file is checked for changes
+	// and pending restrictions are applied
+	rosterCheckInterval = configWatchInterval
+
+	// maxRosterUploadBytes caps the size of a roster sent to /api/roster
+	maxRosterUploadBytes = 10 << 20
+
+	rosterRestrictReason = "No longer on the employee roster"
+)
+
+var errRosterEmpty = errors.New("roster has no email addresses")
+
+// RosterPlan lists the verified members missing from a roster. Unless it's a
+// dry run they are restricted at ApplyAt, giving moderators time to cancel.
+type RosterPlan struct {
+	ID        int64
+	Source    string
+	Emails    map[string]bool // Canonical addresses on the roster
+	Rejected  []string        // Roster entries that aren't valid addresses, with the reason
+	Checked   int             // Verified members compared with the roster
+	Exempt    int             // Missing members left alone because they're allowlisted
+	Departed  []User
+	DryRun    bool
+	ApplyAt   time.Time
+	CreatedAt time.Time
+	ChannelID string
+	MessageID string
+}
+
+// rosterState holds the roster plan waiting to be applied, if any. Plans are
+// kept in memory; after a restart the roster file is loaded again and a new
+// report is posted. mu is only held to read or swap these fields, never
+// during Discord calls.
+type rosterState struct {
+	mu     sync.Mutex
+	plan   *RosterPlan
+	nextID int64
+	latest int64 // ID of the newest report posted
+}
+
+// actionable reports whether the plan will restrict anyone
+func (p *RosterPlan) actionable() bool {
+	return !p.DryRun && len(p.Departed) > 0
+}
+
+// State is the plan's outcome as reported by /api/roster
+func (p *RosterPlan) State() string {
+	switch {
+	case len(p.Departed) == 0:
+		return "no_changes"
+	case p.DryRun:
+		return "dry_run"
+	default:
+		return "scheduled"
+	}
+}
+
+// rosterFormat picks "json" or "csv" from a file name or content type, falling
+// back to looking at the data itself
+func rosterFormat(name, contentType string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".json" || strings.Contains(contentType, "json"):
+		return "json"
+	case ext == ".csv" || strings.Contains(contentType, "csv"):
+		return "csv"
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return "json"
+	}
+	return "csv"
+}
+
+// parseRoster reads the email addresses from a roster export and normalizes
+// them. Blank entries are skipped and entries that aren't valid addresses are
+// returned as rejected so they can be listed in the report. A roster with no
+// valid address at all is an error, so a malformed export can't restrict everyone.
+func parseRoster(data []byte, format, emailColumn string) (emails, rejected []string, err error) {
+	var values []string
+	if format == "json" {
+		values, err = parseRosterJSON(data, emailColumn)
+	} else {
+		values, err = parseRosterCSV(data, emailColumn)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	emails = make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		email, err := normalizeEmail(value)
+		if err != nil {
+			rejected = append(rejected, fmt.Sprintf("%q: %v", value, err))
+			continue
+		}
+		emails = append(emails, email)
+	}
+	if len(emails) == 0 {
+		if len(rejected) > 0 {
+			return nil, rejected, fmt.Errorf("none of the %d entries is a valid email address, e.g. %s", len(rejected), rejected[0])
+		}
+		return nil, nil, errRosterEmpty
+	}
+	return emails, rejected, nil
+}
+
+// parseRosterCSV reads the email column named in the header row. A file with a
+// single column may leave the header out.
+func parseRosterCSV(data []byte, emailColumn string) ([]string, error) {
+	// Spreadsheet exports often start with a byte order mark
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errRosterEmpty
+	}
+
+	column := -1
+	for n, field := range records[0] {
+		if strings.EqualFold(strings.TrimSpace(field), emailColumn) {
+			column = n
+			break
+		}
+	}
+	switch {
+	case column >= 0:
+		records = records[1:]
+	case len(records[0]) == 1:
+		column = 0
+		if !strings.Contains(records[0][0], "@") {
+			records = records[1:]
+		}
+	default:
+		return nil, fmt.Errorf("no %q column in the CSV header", emailColumn)
+	}
+
+	emails := make([]string, 0, len(records))
+	for _, record := range records {
+		if column < len(record) {
+			emails = append(emails, record[column])
+		}
+	}
+	return emails, nil
+}
+
+// parseRosterJSON reads a list of addresses, or a list of objects with an
+// email field, e.g. [{"name": "Alice", "email": "alice@company.com"}]
+func parseRosterJSON(data []byte, emailColumn string) ([]string, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid JSON, expected a list of addresses or objects: %w", err)
+	}
+
+	emails := make([]string, 0, len(entries))
+	for n, entry := range entries {
+		var email string
+		if err := json.Unmarshal(entry, &email); err == nil {
+			emails = append(emails, email)
+			continue
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal(entry, &fields); err != nil {
+			return nil, fmt.Errorf("entry %d is neither an address nor an object", n+1)
+		}
+		found := false
+		for key, value := range fields {
+			if !strings.EqualFold(key, emailColumn) {
+				continue
+			}
+			found = true
+			if email, ok := value.(string); ok {
+				emails = append(emails, email)
+			} else if value != nil {
+				return nil, fmt.Errorf("entry %d has a %q field that isn't text", n+1, key)
+			}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("entry %d has no %q field", n+1, emailColumn)
+		}
+	}
+	return emails, nil
+}
+
+// rosterCovers reports whether a user is checked against the roster
+func (b *Bot) rosterCovers(user *User) bool {
+	if !user.Verified {
+		return false
+	}
+	if len(b.config().Roster.Domains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(user.Email, "@")
+	return isDomainApproved(domain, b.config().Roster.Domains)
+}
+
+// isAllowlisted reports whether an address, as typed or in canonical form, is on the allowlist
+func (b *Bot) isAllowlisted(email string) (bool, error) {
+	for _, candidate := range []string{email, b.config().CanonicalEmail(email)} {
+		rule, err := b.db.GetEmailRule(candidate)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if rule != nil && rule.Rule == EmailRuleAllow {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SyncRoster compares verified members with a new roster and posts the ones
+// missing from it, and any rejected roster entries, to the approval channel. Unless roster.dry_run is set they
+// are restricted once roster.apply_delay_minutes has passed. A newer roster
+// replaces any plan still waiting, and nothing is scheduled if the report
+// can't be posted.
+func (b *Bot) SyncRoster(emails, rejected []string, source string) (*RosterPlan, error) {
+	if len(emails) == 0 {
+		return nil, errRosterEmpty
+	}
+
+	plan := &RosterPlan{
+		Source:    source,
+		Emails:    make(map[string]bool, len(emails)),
+		Rejected:  rejected,
+		DryRun:    b.config().Roster.DryRun,
+		CreatedAt: time.Now(),
+	}
+	plan.ApplyAt = plan.CreatedAt.Add(b.config().RosterApplyDelay())
+	for _, email := range emails {
+		plan.Emails[b.config().CanonicalEmail(email)] = true
+	}
+
+	users, err := b.db.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	if err := b.diffRoster(plan, users); err != nil {
+		return nil, err
+	}
+
+	LogInfo("Roster from %s: %d addresses, %d verified members checked, %d missing", source, len(plan.Emails), plan.Checked, len(plan.Departed))
+	for _, entry := range plan.Rejected {
+		LogWarn("Roster entry rejected: %s", entry)
+	}
+	for _, user := range plan.Departed {
+		LogInfo("Not on roster: %s (%s)", user.DiscordUsername, user.Email)
+	}
+
+	b.roster.mu.Lock()
+	b.roster.nextID++
+	plan.ID = b.roster.nextID
+	b.roster.mu.Unlock()
+
+	send := &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{b.rosterEmbed(plan, rosterPendingStatus(plan))},
+	}
+	if plan.actionable() {
+		send.Components = rosterButtons(plan.ID)
+	}
+	channelID := b.config().Discord.ApprovalChannelID
+	message, err := b.session.ChannelMessageSendComplex(channelID, send)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post roster report: %w", err)
+	}
+	plan.ChannelID, plan.MessageID = channelID, message.ID
+
+	// Another roster may have been posted while this one was; the newest wins
+	b.roster.mu.Lock()
+	var replaced *RosterPlan
+	if plan.ID > b.roster.latest {
+		b.roster.latest = plan.ID
+		replaced = b.roster.plan
+		b.roster.plan = nil
+		if plan.actionable() {
+			b.roster.plan = plan
+		}
+	} else if plan.actionable() {
+		replaced = plan
+	}
+	b.roster.mu.Unlock()
+
+	if replaced != nil {
+		b.editRosterMessage(replaced, "⚠️ Replaced by a newer roster")
+	}
+	return plan, nil
+}
+
+// diffRoster fills in which verified members the roster covers and which of
+// them are missing from it. Allowlisted members are counted as exempt; if the
+// allowlist can't be read the whole roster fails rather than risk restricting them.
+func (b *Bot) diffRoster(plan *RosterPlan, users []User) error {
+	for _, user := range users {
+		if !b.rosterCovers(&user) {
+			continue
+		}
+		plan.Checked++
+		if plan.Emails[b.config().CanonicalEmail(user.Email)] {
+			continue
+		}
+		allowlisted, err := b.isAllowlisted(user.Email)
+		if err != nil {
+			return fmt.Errorf("failed to check the allowlist for %s: %w", user.Email, err)
+		}
+		if allowlisted {
+			plan.Exempt++
+			continue
+		}
+		plan.Departed = append(plan.Departed, user)
+	}
+	return nil
+}
+
+func rosterPendingStatus(plan *RosterPlan) string {
+	switch plan.State() {
+	case "no_changes":
+		return "✅ Every verified member checked is on the roster"
+	case "dry_run":
+		return "🧪 Dry run: `roster.dry_run` is on, so nobody will be restricted"
+	default:
+		return fmt.Sprintf("⏳ These members will be restricted %s unless a moderator cancels", discordTimestamp(plan.ApplyAt))
+	}
+}
+
+func (b *Bot) rosterEmbed(plan *RosterPlan, status string) *discordgo.MessageEmbed {
+	var description strings.Builder
+	if len(plan.Departed) == 0 {
+		description.WriteString("No verified members are missing from the roster.")
+	}
+	for _, user := range plan.Departed {
+		line := fmt.Sprintf("<@%s> (%s)\n", user.DiscordID, user.Email)
+
+		// Leave room for the overflow note
+		if description.Len()+len(line) > 3900 {
+			description.WriteString("…and more. See the logs for the full list.")
+			break
+		}
+		description.WriteString(line)
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Source", Value: truncate(plan.Source, 1024)},
+		{Name: "Addresses on Roster", Value: fmt.Sprintf("%d", len(plan.Emails)), Inline: true},
+		{Name: "Members Checked", Value: fmt.Sprintf("%d", plan.Checked), Inline: true},
+		{Name: "Missing", Value: fmt.Sprintf("%d", len(plan.Departed)), Inline: true},
+	}
+	if plan.Exempt > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Allowlisted (Skipped)", Value: fmt.Sprintf("%d", plan.Exempt), Inline: true})
+	}
+	if len(plan.Rejected) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Rejected Entries (%d)", len(plan.Rejected)),
+			Value: rejectedRosterList(plan.Rejected),
+		})
+	}
+	fields = append(fields, &discordgo.MessageEmbedField{Name: "Status", Value: status})
+
+	color := 0x2ecc71
+	if len(plan.Departed) > 0 {
+		color = 0xe67e22
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "🗂️ Roster Sync",
+		Description: description.String(),
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   plan.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// rejectedRosterList renders rejected roster entries for an embed field, which
+// holds at most 1024 characters
+func rejectedRosterList(rejected []string) string {
+	var list strings.Builder
+	for n, entry := range rejected {
+		line := truncate(entry, 200) + "\n"
+		if list.Len()+len(line) > 960 {
+			fmt.Fprintf(&list, "…and %d more. See the logs for the full list.", len(rejected)-n)
+			break
+		}
+		list.WriteString(line)
+	}
+	return list.String()
+}
+
+func rosterButtons(id int64) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Restrict Now",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%sapply:%d", rosterCustomIDPrefix, id),
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("%scancel:%d", rosterCustomIDPrefix, id),
+				},
+			},
+		},
+	}
+}
+
+// editRosterMessage replaces a roster report's status and removes its buttons
+func (b *Bot) editRosterMessage(plan *RosterPlan, status string) {
+	embeds := []*discordgo.MessageEmbed{b.rosterEmbed(plan, status)}
+	components := []discordgo.MessageComponent{}
+	_, err := b.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         plan.MessageID,
+		Channel:    plan.ChannelID,
+		Embeds:     &embeds,
+		Components: &components,
+	})
+	if err != nil {
+		LogError("Error updating roster report %d: %v", plan.ID, err)
+	}
+}
+
+// applyDueRosterPlan restricts the members in the pending roster plan once its
+// review period is over. A plan left waiting when roster sync is turned off or
+// switched to a dry run is dropped instead.
+func (b *Bot) applyDueRosterPlan() {
+	plan, cancelled := b.takeDueRosterPlan()
+	switch {
+	case plan == nil:
+		return
+	case cancelled:
+		LogInfo("Dropped roster plan %d: roster sync was turned off or switched to a dry run", plan.ID)
+		b.editRosterMessage(plan, "⚠️ Cancelled: roster sync was turned off or switched to a dry run")
+	default:
+		b.editRosterMessage(plan, b.applyRosterPlan(plan, nil))
+	}
+}
+
+// takeDueRosterPlan removes the pending plan if it's due or should be dropped,
+// so it can be handled without holding the lock during Discord calls
+func (b *Bot) takeDueRosterPlan() (plan *RosterPlan, cancelled bool) {
+	b.roster.mu.Lock()
+	defer b.roster.mu.Unlock()
+
+	plan = b.roster.plan
+	if plan == nil {
+		return nil, false
+	}
+	cancelled = !b.config().Roster.Enabled || b.config().Roster.DryRun
+	if !cancelled && time.Now().Before(plan.ApplyAt) {
+		return nil, false
+	}
+	b.roster.plan = nil
+	return plan, cancelled
+}
+
+// applyRosterPlan restricts each member in the plan who is still verified with
+// an address missing from the roster, and returns a status line for the report.
+// actor is the moderator who applied it early, or nil when it was applied on schedule.
+func (b *Bot) applyRosterPlan(plan *RosterPlan, actor *discordgo.User) string {
+	var restricted, skipped, failed int
+	for _, departed := range plan.Departed {
+		user, err := b.db.GetUserByDiscordID(departed.DiscordID)
+		if err != nil && err != sql.ErrNoRows {
+			LogError("Roster sync: error getting %s: %v", departed.DiscordUsername, err)
+			failed++
+			continue
+		}
+		// They may have been restricted, reset or reverified with another address since the report
+		if err == sql.ErrNoRows || !user.Verified || plan.Emails[b.config().CanonicalEmail(user.Email)] {
+			skipped++
+			continue
+		}
+
+		if err := b.db.UnverifyUser(user.DiscordID, nil); err != nil {
+			LogError("Roster sync: error restricting %s: %v", user.DiscordUsername, err)
+			failed++
+			continue
+		}
+		b.revokeVerifiedRoles(user)
+
+		before, after := auditState(user), auditState(&User{Unverified: true, Teams: user.Teams})
+		event := Event{
+			Type:     EventRestriction,
+			UserID:   user.DiscordID,
+			Username: user.DiscordUsername,
+			Team:     user.TeamNames(),
+			Reason:   rosterRestrictReason,
+		}
+		if actor != nil {
+			b.recordAudit(AuditEntry{
+				Action:      AuditActionRestrict,
+				ActorID:     actor.ID,
+				ActorName:   actor.Username,
+				TargetID:    user.DiscordID,
+				TargetName:  user.DiscordUsername,
+				Reason:      rosterRestrictReason,
+				BeforeState: before,
+				AfterState:  after,
+			})
+			event.ActorID, event.ActorName = actor.ID, actor.Username
+		} else {
+			b.auditAutomatic(AuditActionRestrict, user.DiscordID, user.DiscordUsername, rosterRestrictReason, before, after)
+		}
+		b.events.Publish(event)
+
+		LogWarn("Roster sync: restricted %s (%s)", user.DiscordUsername, user.Email)
+		b.SendDM(user.DiscordID, "⚠️ Your server access has been restricted because your email address is no longer on the employee roster.\n\nIf you think this is a mistake, please contact a moderator.")
+		restricted++
+	}
+
+	LogSuccess("Roster plan %d applied: %d restricted, %d skipped, %d failed", plan.ID, restricted, skipped, failed)
+
+	status := fmt.Sprintf("✅ Restricted %d of %d members", restricted, len(plan.Departed))
+	if actor != nil {
+		status += fmt.Sprintf(" (applied early by <@%s>)", actor.ID)
+	}
+	if skipped > 0 {
+		status += fmt.Sprintf("\n%d skipped: restricted, removed or verified with a roster address since the report", skipped)
+	}
+	if failed > 0 {
+		status += fmt.Sprintf("\n❌ %d failed. Check the logs for details.", failed)
+	}
+	return status
+}
+
+// handleRosterButton handles Restrict Now and Cancel on a roster report
+func (b *Bot) handleRosterButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.isAdmin(i.Member) {
+		b.respondEphemeral(s, i, "❌ You don't have permission to do this.")
+		return
+	}
+
+	action, idText, _ := strings.Cut(strings.TrimPrefix(i.MessageComponentData().CustomID, rosterCustomIDPrefix), ":")
+	id, err := strconv.ParseInt(idText, 10, 64)
+
+	var plan *RosterPlan
+	if err == nil {
+		plan = b.takeRosterPlan(id)
+	}
+	if plan == nil {
+		b.respondEphemeral(s, i, "❌ This roster sync is no longer pending. It was applied, cancelled or replaced by a newer roster, or Heimdall restarted.")
+		return
+	}
+	moderator := i.Member.User
+
+	// Restricting many members can take longer than Discord's 3 second response window
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+
+	if action == "apply" {
+		LogInfo("Moderator %s applied roster plan %d early", moderator.Username, plan.ID)
+		b.editRosterMessage(plan, b.applyRosterPlan(plan, moderator))
+		return
+	}
+
+	LogInfo("Moderator %s cancelled roster plan %d", moderator.Username, plan.ID)
+	b.editRosterMessage(plan, fmt.Sprintf("🚫 Cancelled by <@%s>", moderator.ID))
+}
+
+// takeRosterPlan removes and returns the pending plan if its ID matches
+func (b *Bot) takeRosterPlan(id int64) *RosterPlan {
+	b.roster.mu.Lock()
+	defer b.roster.mu.Unlock()
+
+	plan := b.roster.plan
+	if plan == nil || plan.ID != id {
+		return nil
+	}
+	b.roster.plan = nil
+	return plan
+}
+
+// RosterWatcher loads roster.file whenever it changes and applies roster
+// plans once their review period is over. An export caught half written is
+// replaced by the complete one on the next check, well before it could apply.
+type RosterWatcher struct {
+	configs *ConfigStore
+	bot     *Bot
+	path    string
+	modTime time.Time
+	size    int64
+	warned  bool // Whether the current file's read error has been logged
+	stop    chan struct{}
+}
+
+func NewRosterWatcher(configs *ConfigStore, bot *Bot) *RosterWatcher {
+	return &RosterWatcher{
+		configs: configs,
+		bot:     bot,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start watches the roster in the background until Stop is called
+func (rw *RosterWatcher) Start() {
+	go func() {
+		rw.run()
+
+		ticker := time.NewTicker(rosterCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rw.run()
+			case <-rw.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (rw *RosterWatcher) config() *Config {
+	return rw.configs.Get()
+}
+
+func (rw *RosterWatcher) Stop() {
+	close(rw.stop)
+}
+
+func (rw *RosterWatcher) run() {
+	rw.bot.applyDueRosterPlan()
+	if rw.config().Roster.Enabled {
+		rw.checkFile()
+	}
+}
+
+func (rw *RosterWatcher) checkFile() {
+	path := rw.config().Roster.File
+	if path == "" {
+		return
+	}
+	if path != rw.path {
+		rw.path, rw.modTime, rw.size, rw.warned = path, time.Time{}, 0, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if !rw.warned {
+			LogWarn("Can't read roster file %s: %v", path, err)
+			rw.warned = true
+		}
+		return
+	}
+	rw.warned = false
+	if info.ModTime().Equal(rw.modTime) && info.Size() == rw.size {
+		return
+	}
+	rw.modTime, rw.size = info.ModTime(), info.Size()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		LogError("Error reading roster file %s: %v", path, err)
+		return
+	}
+	emails, rejected, err := parseRoster(data, rosterFormat(path, "", data), rw.config().RosterEmailColumn())
+	if err != nil {
+		LogError("Roster file %s ignored: %v", path, err)
+		return
+	}
+
+	LogInfo("Loaded %d addresses from roster file %s (%d entries rejected)", len(emails), path, len(rejected))
+	if _, err := rw.bot.SyncRoster(emails, rejected, "File "+path); err != nil {
+		LogError("Roster sync failed: %v", err)
+	}
+}
+
+// handleAPIRoster accepts a roster from an HR or directory export job, e.g.
+//
+//	curl -H "Authorization: Bearer $TOKEN" -H "Content-Type: text/csv" --data-binary @roster.csv https://heimdall.example.com/api/roster
+func (ws *WebServer) handleAPIRoster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := ws.config().Roster.UploadToken
+	if !ws.config().Roster.Enabled || token == "" {
+		http.Error(w, "Roster upload is not enabled", http.StatusNotFound)
+		return
+	}
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		LogWarn("Rejected roster upload from %s: missing or wrong token", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRosterUploadBytes))
+	if err != nil {
+		http.Error(w, "Roster is too large or could not be read", http.StatusBadRequest)
+		return
+	}
+	emails, rejected, err := parseRoster(data, rosterFormat("", r.Header.Get("Content-Type"), data), ws.config().RosterEmailColumn())
+	if err != nil {
+		LogWarn("Rejected roster upload from %s: %v", r.RemoteAddr, err)
+		http.Error(w, fmt.Sprintf("Invalid roster: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	LogInfo("Received roster upload with %d addresses (%d entries rejected) from %s", len(emails), len(rejected), r.RemoteAddr)
+	plan, err := ws.bot.SyncRoster(emails, rejected, "Upload from "+r.RemoteAddr)
+	if err != nil {
+		LogError("Roster sync failed: %v", err)
+		http.Error(w, "Failed to process roster", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{
+		"status":      plan.State(),
+		"roster_size": len(plan.Emails),
+		"checked":     plan.Checked,
+		"missing":     len(plan.Departed),
+		"rejected":    len(plan.Rejected),
+	}
+	if plan.actionable() {
+		response["apply_at"] = plan.ApplyAt.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRosterFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		data        string
+		want        string
+	}{
+		{"json extension", "roster.json", "", "email\n", "json"},
+		{"csv extension", "ROSTER.CSV", "", `["alice@company.com"]`, "csv"},
+		{"json content type", "", "application/json; charset=utf-8", "", "json"},
+		{"csv content type", "", "text/csv", "", "csv"},
+		{"sniffed list", "", "", "  [\"alice@company.com\"]", "json"},
+		{"sniffed object", "", "application/octet-stream", "{}", "json"},
+		{"sniffed csv", "", "", "email\nalice@company.com\n", "csv"},
+		{"empty", "", "", "", "csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rosterFormat(tt.fileName, tt.contentType, []byte(tt.data)); got != tt.want {
+				t.Errorf("rosterFormat(%q, %q) = %q, want %q", tt.fileName, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoster(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       string
+		data         string
+		want         []string
+		wantRejected int
+		wantErr      bool
+	}{
+		{
+			name:   "csv with header",
+			format: "csv",
+			data:   "name,email\nAlice,alice@company.com\nBob,bob@company.com\n",
+			want:   []string{"alice@company.com", "bob@company.com"},
+		},
+		{
+			name:   "csv header is case insensitive",
+			format: "csv",
+			data:   "Name, Email \nAlice, alice@company.com\n",
+			want:   []string{"alice@company.com"},
+		},
+		{
+			name:   "single column without header",
+			format: "csv",
+			data:   "alice@company.com\nbob@company.com\n",
+			want:   []string{"alice@company.com", "bob@company.com"},
+		},
+		{
+			name:   "single column with header",
+			format: "csv",
+			data:   "email\nalice@company.com\n",
+			want:   []string{"alice@company.com"},
+		},
+		{
+			name:   "byte order mark",
+			format: "csv",
+			data:   "\xef\xbb\xbfemail,name\nalice@company.com,Alice\n",
+			want:   []string{"alice@company.com"},
+		},
+		{
+			name:   "blank csv entries are skipped",
+			format: "csv",
+			data:   "name,email\nAlice,alice@company.com\nVacancy,\nShort row\nBob,  bob@company.com\n",
+			want:   []string{"alice@company.com", "bob@company.com"},
+		},
+		{
+			name:    "missing email column",
+			format:  "csv",
+			data:    "name,department\nAlice,Engineering\n",
+			wantErr: true,
+		},
+		{
+			name:    "header only",
+			format:  "csv",
+			data:    "name,email\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			format:  "csv",
+			data:    "",
+			wantErr: true,
+		},
+		{
+			name:   "json strings",
+			format: "json",
+			data:   `["alice@company.com", " bob@company.com ", ""]`,
+			want:   []string{"alice@company.com", "bob@company.com"},
+		},
+		{
+			name:   "json objects",
+			format: "json",
+			data:   `[{"name": "Alice", "Email": "alice@company.com"}, {"name": "Vacancy", "email": null}, {"name": "Bob", "email": "bob@company.com"}]`,
+			want:   []string{"alice@company.com", "bob@company.com"},
+		},
+		{
+			name:    "json email that isn't text",
+			format:  "json",
+			data:    `[{"name": "Alice", "email": 42}]`,
+			wantErr: true,
+		},
+		{
+			name:    "json object without email",
+			format:  "json",
+			data:    `[{"name": "Alice"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "json that isn't a list",
+			format:  "json",
+			data:    `{"email": "alice@company.com"}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty json list",
+			format:  "json",
+			data:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "all entries invalid",
+			format:  "csv",
+			data:    "name,email\nAlice,Alice Smith\nBob,Bob Jones\n",
+			wantErr: true,
+		},
+		{
+			name:         "invalid entries are rejected",
+			format:       "csv",
+			data:         "name,email\nAlice,alice@company.com\nBob,Bob Jones\nCarol,carol@company..com\n",
+			want:         []string{"alice@company.com"},
+			wantRejected: 2,
+		},
+		{
+			name:   "addresses are normalized",
+			format: "json",
+			data:   `["Alice@Company.COM", "josé@bücher.de"]`,
+			want:   []string{"alice@company.com", "josé@xn--bcher-kva.de"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rejected, err := parseRoster([]byte(tt.data), tt.format, "email")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseRoster() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoster() returned error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseRoster() = %q, want %q", got, tt.want)
+			}
+			if len(rejected) != tt.wantRejected {
+				t.Errorf("parseRoster() rejected %q, want %d entries", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestDiffRoster(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	members := []struct {
+		id       string
+		email    string
+		verified bool
+	}{
+		{"on-roster", "Alice.Smith+discord@company.com", true},
+		{"departed", "bob@company.com", true},
+		{"allowlisted", "carol@company.com", true},
+		{"other-domain", "dave@partner.com", true},
+		{"pending", "erin@company.com", false},
+	}
+	for _, m := range members {
+		if err := db.CreateUser(m.id, m.id, m.email, m.email, "code-"+m.id); err != nil {
+			t.Fatalf("CreateUser(%s): %v", m.id, err)
+		}
+		if m.verified {
+			if err := db.MarkUserVerified(m.id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := db.SetEmailRule(EmailRule{Email: "carol@company.com", Rule: EmailRuleAllow, AddedByID: "1", AddedByName: "mod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := validConfig()
+	config.Roster.Domains = []string{"company.com"}
+	config.EmailNormalization.StripPlusTags = true
+	bot := &Bot{configs: NewConfigStore("", config), db: db}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := &RosterPlan{Emails: map[string]bool{"alice.smith@company.com": true}}
+	if err := bot.diffRoster(plan, users); err != nil {
+		t.Fatalf("diffRoster: %v", err)
+	}
+
+	var departed []string
+	for _, user := range plan.Departed {
+		departed = append(departed, user.DiscordID)
+	}
+	if want := []string{"departed"}; !slices.Equal(departed, want) {
+		t.Errorf("departed = %v, want %v", departed, want)
+	}
+	if plan.Checked != 3 {
+		t.Errorf("checked = %d, want 3 (the verified company.com members)", plan.Checked)
+	}
+	if plan.Exempt != 1 {
+		t.Errorf("exempt = %d, want 1 (the allowlisted member)", plan.Exempt)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Team change actions, shared by /heimdall-changeteam and /heimdall-myteam
+const (
+	TeamActionSet    = "set"    // Replace all of the user's teams with one
+	TeamActionAdd    = "add"    // Put the user on another team
+	TeamActionRemove = "remove" // Take the user off one of their teams
+)
+
+var (
+	errAlreadyOnTeam  = errors.New("already on team")
+	errNotOnTeam      = errors.New("not on team")
+	errLastTeam       = errors.New("can't remove last team")
+	errTeamRoleFailed = errors.New("failed to assign team role")
+)
+
+// teamActionOption builds the optional action option shared by the team change commands
+func teamActionOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "action",
+		Description: "set replaces all teams (default); add and remove change one",
+		Required:    false,
+		Choices: []*discordgo.ApplicationCommandOptionChoice{
+			{Name: TeamActionSet, Value: TeamActionSet},
+			{Name: TeamActionAdd, Value: TeamActionAdd},
+			{Name: TeamActionRemove, Value: TeamActionRemove},
+		},
+	}
+}
+
+// checkTeamChange returns errAlreadyOnTeam, errNotOnTeam or errLastTeam if
+// the change would do nothing or leave the user without a team
+func checkTeamChange(user *User, action, team string) error {
+	switch action {
+	case TeamActionAdd:
+		if user.HasTeam(team) {
+			return errAlreadyOnTeam
+		}
+	case TeamActionRemove:
+		if !user.HasTeam(team) {
+			return errNotOnTeam
+		}
+		if len(user.Teams) == 1 {
+			return errLastTeam
+		}
+	default:
+		if len(user.Teams) == 1 && user.Teams[0] == team {
+			return errAlreadyOnTeam
+		}
+	}
+	return nil
+}
+
+// applyTeamChange updates a verified user's team roles in Discord and then the
+// database, returning their new teams. A failed role assignment is reported
+// as errTeamRoleFailed before anything is saved.
+func (b *Bot) applyTeamChange(user *User, action, team string) ([]string, error) {
+	var newTeams []string
+
+	switch action {
+	case TeamActionAdd:
+		if err := b.assignTeamRoles(user.DiscordID, []string{team}, user.DiscordUsername); err != nil {
+			return nil, fmt.Errorf("%w: %v", errTeamRoleFailed, err)
+		}
+		if _, err := b.db.AddUserTeam(user.DiscordID, team); err != nil {
+			return nil, err
+		}
+		newTeams = append(slices.Clone(user.Teams), team)
+
+	case TeamActionRemove:
+		b.removeTeamRoles(user.DiscordID, []string{team}, user.DiscordUsername)
+		if _, err := b.db.RemoveUserTeam(user.DiscordID, team); err != nil {
+			return nil, err
+		}
+		newTeams = slices.DeleteFunc(slices.Clone(user.Teams), func(t string) bool { return t == team })
+
+	default:
+		// Grant the new role before dropping the old ones so a failed
+		// assignment never leaves the user with no team role at all
+		if err := b.assignTeamRoles(user.DiscordID, []string{team}, user.DiscordUsername); err != nil {
+			return nil, fmt.Errorf("%w: %v", errTeamRoleFailed, err)
+		}
+		dropped := slices.DeleteFunc(slices.Clone(user.Teams), func(t string) bool { return t == team })
+		b.removeTeamRoles(user.DiscordID, dropped, user.DiscordUsername)
+		if err := b.db.SetUserTeams(user.DiscordID, []string{team}); err != nil {
+			return nil, err
+		}
+		newTeams = []string{team}
+	}
+
+	sort.Strings(newTeams)
+	return newTeams, nil
+}
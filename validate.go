@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ConfigErrors collects every problem found in a config file so they can all be fixed in one go
+type ConfigErrors []error
+
+func (ce ConfigErrors) Error() string {
+	messages := make([]string, len(ce))
+	for n, err := range ce {
+		messages[n] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the config for missing or malformed settings. It returns
+// nil or a ConfigErrors listing every problem found.
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// Discord
+	if c.Discord.Token == "" {
+		add("discord.token is required")
+	}
+	if c.Discord.GuildID == "" {
+		add("discord.guild_id is required")
+	} else if !isSnowflake(c.Discord.GuildID) {
+		add("discord.guild_id %q is not a valid Discord ID", c.Discord.GuildID)
+	}
+	if c.Discord.AdminRole != "" && !isSnowflake(c.Discord.AdminRole) {
+		add("discord.admin_role %q is not a valid role ID", c.Discord.AdminRole)
+	}
+	if c.Discord.MembersRole != "" && !isSnowflake(c.Discord.MembersRole) {
+		add("discord.members_role %q is not a valid role ID", c.Discord.MembersRole)
+	}
+	if c.Discord.AuditChannelID != "" && !isSnowflake(c.Discord.AuditChannelID) {
+		add("discord.audit_channel_id %q is not a valid channel ID", c.Discord.AuditChannelID)
+	}
+	if c.Discord.ApprovalChannelID != "" && !isSnowflake(c.Discord.ApprovalChannelID) {
+		add("discord.approval_channel_id %q is not a valid channel ID", c.Discord.ApprovalChannelID)
+	}
+	for _, key := range sortedKeys(c.Discord.AuditEvents) {
+		if !isAuditEventType(key) {
+			add("discord.audit_events.%s is not a known event", key)
+		}
+	}
+
+	// Email
+	if c.Email.SMTPHost == "" {
+		add("email.smtp_host is required")
+	}
+	if c.Email.SMTPPort <= 0 || c.Email.SMTPPort > 65535 {
+		add("email.smtp_port %d is out of range", c.Email.SMTPPort)
+	}
+	if c.Email.FromAddress == "" {
+		add("email.from_address is required")
+	} else if !isValidEmail(c.Email.FromAddress) {
+		add("email.from_address %q is not a valid email address", c.Email.FromAddress)
+	}
+
+	// Server
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		add("server.port %d is out of range", c.Server.Port)
+	}
+	if c.Server.BaseURL == "" {
+		add("server.base_url is required")
+	} else if u, err := url.Parse(c.Server.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("server.base_url %q must be an absolute http(s) URL", c.Server.BaseURL)
+	}
+	switch strings.ToUpper(c.Server.LogLevel) {
+	case "", "ERROR", "WARN", "WARNING", "INFO", "DEBUG":
+	default:
+		add("server.log_level %q must be one of ERROR, WARN, INFO, DEBUG", c.Server.LogLevel)
+	}
+
+	// Durations and limits; zero means "use the default"
+	if c.Verification.CodeExpiryHours < 0 {
+		add("verification.code_expiry_hours must not be negative")
+	}
+	if c.Verification.CleanupIntervalMinutes < 0 {
+		add("verification.cleanup_interval_minutes must not be negative")
+	}
+	if c.Verification.ResendCooldownMinutes < 0 {
+		add("verification.resend_cooldown_minutes must not be negative")
+	}
+	if c.Verification.MaxResendsPerDay < 0 {
+		add("verification.max_resends_per_day must not be negative")
+	}
+	if c.RoleSync.IntervalMinutes < 0 {
+		add("role_sync.interval_minutes must not be negative")
+	}
+	if c.Reverification.IntervalDays < 0 {
+		add("reverification.interval_days must not be negative")
+	}
+	if c.Reverification.GraceDays < 0 {
+		add("reverification.grace_days must not be negative")
+	}
+	if c.Roster.ApplyDelayMinutes < 0 {
+		add("roster.apply_delay_minutes must not be negative")
+	}
+	if c.Roster.Enabled && c.Roster.File == "" && c.Roster.UploadToken == "" {
+		add("roster.enabled requires roster.file or roster.upload_token")
+	}
+	if c.Roster.Enabled && c.Discord.ApprovalChannelID == "" {
+		add("roster.enabled requires discord.approval_channel_id")
+	}
+	if c.TeamChanges.CooldownHours < 0 {
+		add("team_changes.cooldown_hours must not be negative")
+	}
+	if c.TeamChanges.Enabled && !c.Features.EnableTeamSelection {
+		add("team_changes.enabled requires features.enable_team_selection")
+	}
+	if c.TeamChanges.RequireApproval && c.Discord.ApprovalChannelID == "" {
+		add("team_changes.require_approval requires discord.approval_channel_id")
+	}
+	if c.Features.RequireApproval && c.Discord.ApprovalChannelID == "" {
+		add("features.require_approval requires discord.approval_channel_id")
+	}
+
+	// Domains and teams
+	for _, domain := range c.ApprovedDomains {
+		if !isValidDomainPattern(domain) {
+			add("approved_domains entry %q is not a valid domain or pattern", domain)
+		}
+	}
+	for _, domain := range c.EmailNormalization.DotFoldingDomains {
+		if strings.HasPrefix(domain, "!") || !isValidDomainPattern(domain) {
+			add("email_normalization.dot_folding_domains entry %q must be a domain or *.domain", domain)
+		}
+	}
+	for _, domain := range c.Roster.Domains {
+		if !isValidDomainPattern(domain) {
+			add("roster.domains entry %q is not a valid domain or pattern", domain)
+		}
+	}
+	if c.Features.EnableTeamSelection && len(c.Teams) == 0 {
+		add("teams must list at least one team when features.enable_team_selection is on")
+	}
+	for _, team := range sortedKeys(c.Teams) {
+		if !isSnowflake(c.Teams[team]) {
+			add("teams.%s role ID %q is not a valid role ID", team, c.Teams[team])
+		}
+	}
+	for _, pattern := range sortedKeys(c.DomainSettings) {
+		settings := c.DomainSettings[pattern]
+		if strings.HasPrefix(pattern, "!") || !isValidDomainPattern(pattern) {
+			add("domain_settings key %q must be a domain or *.domain", pattern)
+		}
+		if settings.LockedTeam != "" && settings.DefaultTeam != "" {
+			add("domain_settings.%s: set either locked_team or default_team, not both", pattern)
+		}
+		for _, team := range append([]string{settings.DefaultTeam, settings.LockedTeam}, settings.Teams...) {
+			if _, exists := c.Teams[team]; team != "" && !exists {
+				add("domain_settings.%s refers to unknown team %q", pattern, team)
+			}
+		}
+		if settings.DefaultTeam != "" && len(settings.Teams) > 0 && !slices.Contains(settings.Teams, settings.DefaultTeam) {
+			add("domain_settings.%s default_team %q is not in its teams list", pattern, settings.DefaultTeam)
+		}
+		for _, roleID := range settings.ExtraRoles {
+			if !isSnowflake(roleID) {
+				add("domain_settings.%s extra role %q is not a valid role ID", pattern, roleID)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// CheckDiscord verifies through the Discord API that the configured guild,
+// roles and channels exist and that the bot token can see them
+func (c *Config) CheckDiscord() error {
+	session, err := discordgo.New("Bot " + c.Discord.Token)
+	if err != nil {
+		return ConfigErrors{fmt.Errorf("discord.token: %v", err)}
+	}
+
+	var errs ConfigErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, err := session.Guild(c.Discord.GuildID); err != nil {
+		return ConfigErrors{fmt.Errorf("discord.guild_id %s: unable to access guild: %v", c.Discord.GuildID, err)}
+	}
+
+	roles, err := session.GuildRoles(c.Discord.GuildID)
+	if err != nil {
+		return ConfigErrors{fmt.Errorf("unable to list roles in guild %s: %v", c.Discord.GuildID, err)}
+	}
+	roleExists := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		roleExists[role.ID] = true
+	}
+
+	if c.Discord.AdminRole != "" && !roleExists[c.Discord.AdminRole] {
+		add("discord.admin_role %s does not exist in the guild", c.Discord.AdminRole)
+	}
+	if c.Discord.MembersRole != "" && !roleExists[c.Discord.MembersRole] {
+		add("discord.members_role %s does not exist in the guild", c.Discord.MembersRole)
+	}
+	for _, team := range sortedKeys(c.Teams) {
+		if !roleExists[c.Teams[team]] {
+			add("teams.%s role %s does not exist in the guild", team, c.Teams[team])
+		}
+	}
+
+	for _, pattern := range sortedKeys(c.DomainSettings) {
+		for _, roleID := range c.DomainSettings[pattern].ExtraRoles {
+			if !roleExists[roleID] {
+				add("domain_settings.%s extra role %s does not exist in the guild", pattern, roleID)
+			}
+		}
+	}
+
+	channels := []struct{ setting, id string }{
+		{"discord.audit_channel_id", c.Discord.AuditChannelID},
+		{"discord.approval_channel_id", c.Discord.ApprovalChannelID},
+	}
+	for _, channel := range channels {
+		if channel.id == "" {
+			continue
+		}
+		found, err := session.Channel(channel.id)
+		if err != nil {
+			add("%s %s: unable to access channel: %v", channel.setting, channel.id, err)
+		} else if found.GuildID != c.Discord.GuildID {
+			add("%s %s is not in guild %s", channel.setting, channel.id, c.Discord.GuildID)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// isSnowflake reports whether s looks like a Discord ID
+func isSnowflake(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+func isAuditEventType(key string) bool {
+	switch EventType(key) {
+	case EventVerification, EventTeamChange, EventRestriction, EventUnrestrict, EventReset, EventPurge, EventRoleSync:
+		return true
+	}
+	return false
+}
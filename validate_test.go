@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validConfig returns a config that passes Validate, for tests to break one setting at a time
+func validConfig() *Config {
+	c := &Config{}
+	c.Discord.Token = "token"
+	c.Discord.GuildID = "100000000000000001"
+	c.Discord.AdminRole = "100000000000000002"
+	c.Email.SMTPHost = "smtp.company.com"
+	c.Email.SMTPPort = 587
+	c.Email.FromAddress = "heimdall@company.com"
+	c.Server.Port = 8080
+	c.Server.BaseURL = "https://heimdall.company.com"
+	c.ApprovedDomains = []string{"company.com"}
+	c.Teams = map[string]string{"Engineering": "100000000000000003"}
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // Expected in the error; empty when the config is valid
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"log level lowercase", func(c *Config) { c.Server.LogLevel = "debug" }, ""},
+		{"audit events", func(c *Config) { c.Discord.AuditEvents = map[string]bool{"verification": false} }, ""},
+		{"no token", func(c *Config) { c.Discord.Token = "" }, "discord.token is required"},
+		{"no guild", func(c *Config) { c.Discord.GuildID = "" }, "discord.guild_id is required"},
+		{"bad guild", func(c *Config) { c.Discord.GuildID = "my-server" }, "discord.guild_id"},
+		{"bad admin role", func(c *Config) { c.Discord.AdminRole = "Moderators" }, "discord.admin_role"},
+		{"bad members role", func(c *Config) { c.Discord.MembersRole = "@members" }, "discord.members_role"},
+		{"bad audit channel", func(c *Config) { c.Discord.AuditChannelID = "#audit" }, "discord.audit_channel_id"},
+		{"unknown audit event", func(c *Config) { c.Discord.AuditEvents = map[string]bool{"lunch": true} }, "discord.audit_events.lunch"},
+		{"no SMTP host", func(c *Config) { c.Email.SMTPHost = "" }, "email.smtp_host is required"},
+		{"SMTP port out of range", func(c *Config) { c.Email.SMTPPort = 70000 }, "email.smtp_port"},
+		{"no from address", func(c *Config) { c.Email.FromAddress = "" }, "email.from_address is required"},
+		{"bad from address", func(c *Config) { c.Email.FromAddress = "heimdall" }, "email.from_address"},
+		{"server port out of range", func(c *Config) { c.Server.Port = 0 }, "server.port"},
+		{"no base URL", func(c *Config) { c.Server.BaseURL = "" }, "server.base_url is required"},
+		{"relative base URL", func(c *Config) { c.Server.BaseURL = "heimdall.company.com" }, "server.base_url"},
+		{"bad log level", func(c *Config) { c.Server.LogLevel = "LOUD" }, "server.log_level"},
+		{"negative code expiry", func(c *Config) { c.Verification.CodeExpiryHours = -1 }, "verification.code_expiry_hours"},
+		{"negative cleanup interval", func(c *Config) { c.Verification.CleanupIntervalMinutes = -1 }, "verification.cleanup_interval_minutes"},
+		{"negative resend cooldown", func(c *Config) { c.Verification.ResendCooldownMinutes = -1 }, "verification.resend_cooldown_minutes"},
+		{"negative resend limit", func(c *Config) { c.Verification.MaxResendsPerDay = -1 }, "verification.max_resends_per_day"},
+		{"negative role sync interval", func(c *Config) { c.RoleSync.IntervalMinutes = -1 }, "role_sync.interval_minutes"},
+		// Domains can be added at runtime with /heimdall-domains, so none in the file is allowed
+		{"no approved domains", func(c *Config) { c.ApprovedDomains = nil }, ""},
+		{"domain patterns", func(c *Config) { c.ApprovedDomains = []string{"*.company.com", "!contractors.company.com"} }, ""},
+		{"address as domain", func(c *Config) { c.ApprovedDomains = []string{"alice@company.com"} }, "approved_domains entry"},
+		{"bad domain pattern", func(c *Config) { c.ApprovedDomains = []string{"company.com", "eng.*.company.com"} }, "approved_domains entry"},
+		{"team selection without teams", func(c *Config) {
+			c.Features.EnableTeamSelection = true
+			c.Teams = nil
+		}, "teams must list at least one team"},
+		{"bad team role", func(c *Config) { c.Teams["Sales"] = "sales-role" }, "teams.Sales"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(c)
+			err := c.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := validConfig()
+	c.Discord.Token = ""
+	c.Email.SMTPHost = ""
+	c.Server.Port = -1
+
+	var errs ConfigErrors
+	if err := c.Validate(); !errors.As(err, &errs) {
+		t.Fatalf("Validate() = %v, want ConfigErrors", err)
+	}
+	if len(errs) != 3 {
+		t.Errorf("Validate() found %d problems, want 3: %v", len(errs), errs)
+	}
+}
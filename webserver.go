@@ -5,25 +5,45 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
 type WebServer struct {
-	config    *Config
+	configs   *ConfigStore
 	db        *Database
 	bot       *Bot
+	events    *EventBus
 	startTime time.Time
 }
 
-func NewWebServer(config *Config, db *Database, bot *Bot) *WebServer {
+func NewWebServer(configs *ConfigStore, db *Database, bot *Bot, events *EventBus) *WebServer {
 	return &WebServer{
-		config:    config,
+		configs:   configs,
 		db:        db,
 		bot:       bot,
+		events:    events,
 		startTime: time.Now(),
 	}
 }
 
+func (ws *WebServer) config() *Config {
+	return ws.configs.Get()
+}
+
+const emailNotPermittedMessage = "This email address can no longer be used for verification. Please contact a moderator."
+
+// refuseEmail answers a verification request whose address checkEmailAccess didn't approve
+func refuseEmail(w http.ResponseWriter, access emailAccess) {
+	if access == emailCheckFailed {
+		http.Error(w, "We couldn't check your email address right now. Please try again in a few minutes.", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, emailNotPermittedMessage, http.StatusForbidden)
+}
+
 // truncateCode safely truncates a verification code for logging
 func truncateCode(code string) string {
 	if len(code) <= 8 {
@@ -35,10 +55,11 @@ func truncateCode(code string) string {
 func (ws *WebServer) Start() error {
 	http.HandleFunc("/verify", ws.handleVerify)
 	http.HandleFunc("/api/verify", ws.handleAPIVerify)
+	http.HandleFunc("/api/roster", ws.handleAPIRoster)
 	http.HandleFunc("/health", ws.handleHealth)
 	http.HandleFunc("/status", ws.handleStatus)
 
-	addr := fmt.Sprintf(":%d", ws.config.Server.Port)
+	addr := fmt.Sprintf(":%d", ws.config().Server.Port)
 	return http.ListenAndServe(addr, nil)
 }
 
@@ -51,19 +72,31 @@ func (ws *WebServer) handleVerify(w http.ResponseWriter, r *http.Request) {
 
 	LogDebug("Web verification page accessed with code: %s", truncateCode(code))
 
-	user, err := ws.db.GetUserByVerificationCode(code)
+	user, err := ws.db.GetUserByVerificationCode(code, ws.config().CodeTTL())
+	if err == ErrVerificationCodeExpired {
+		LogInfo("Expired verification code accessed by %s: %s", user.DiscordUsername, truncateCode(code))
+		http.Error(w, "This verification link has expired. Send your work email to the bot again to get a new one.", http.StatusGone)
+		return
+	}
 	if err != nil {
 		LogWarn("Invalid verification code accessed: %s", truncateCode(code))
 		http.Error(w, "Invalid or expired verification code", http.StatusNotFound)
 		return
 	}
 
-	if user.Verified {
+	// Verified members who've been asked to reverify get a confirmation page
+	if (user.Verified && user.ReverifyAskedAt == nil) || user.AwaitingApproval {
 		LogDebug("Already verified user accessing verification page: %s", user.DiscordUsername)
 		ws.renderAlreadyVerified(w, user)
 		return
 	}
 
+	if access := ws.bot.checkEmailAccess(user.Email); access != emailApproved {
+		LogWarn("Verification page refused for %s: %s is blocked, no longer approved or couldn't be checked", user.DiscordUsername, user.Email)
+		refuseEmail(w, access)
+		return
+	}
+
 	LogDebug("Rendering verification page for: %s", user.DiscordUsername)
 	ws.renderVerificationPage(w, user)
 }
@@ -75,8 +108,9 @@ func (ws *WebServer) handleAPIVerify(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Code string `json:"code"`
-		Team string `json:"team"`
+		Code  string   `json:"code"`
+		Teams []string `json:"teams"`
+		Team  string   `json:"team"` // Single team, from pages served before multiple teams were supported
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -89,57 +123,97 @@ func (ws *WebServer) handleAPIVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only require team if feature is enabled
-	if ws.config.Features.EnableTeamSelection && req.Team == "" {
-		http.Error(w, "Team is required", http.StatusBadRequest)
-		return
-	}
-
-	if ws.config.Features.EnableTeamSelection {
-		LogDebug("Web verification attempt: code=%s team=%s", truncateCode(req.Code), req.Team)
+	if ws.config().Features.EnableTeamSelection {
+		LogDebug("Web verification attempt: code=%s teams=%v", truncateCode(req.Code), append(req.Teams, req.Team))
 	} else {
 		LogDebug("Web verification attempt: code=%s", truncateCode(req.Code))
 	}
 
-	user, err := ws.db.GetUserByVerificationCode(req.Code)
+	user, err := ws.db.GetUserByVerificationCode(req.Code, ws.config().CodeTTL())
+	if err == ErrVerificationCodeExpired {
+		LogInfo("Expired verification code used by %s: %s", user.DiscordUsername, truncateCode(req.Code))
+		http.Error(w, "This verification link has expired. Send your work email to the bot again to get a new one.", http.StatusGone)
+		return
+	}
 	if err != nil {
 		LogWarn("Invalid verification code used: %s", truncateCode(req.Code))
 		http.Error(w, "Invalid verification code", http.StatusNotFound)
 		return
 	}
 
-	if user.Verified {
+	if user.Verified && user.ReverifyAskedAt == nil {
 		LogDebug("User %s already verified, ignoring web verification", user.DiscordUsername)
 		http.Error(w, "User already verified", http.StatusBadRequest)
 		return
 	}
+	if user.AwaitingApproval {
+		LogDebug("User %s already awaiting approval, ignoring web verification", user.DiscordUsername)
+		http.Error(w, "Your verification is already waiting for a moderator's approval", http.StatusBadRequest)
+		return
+	}
 
-	var roleID string
-	var teamName string
+	// The blocklist or approved domains may have changed since the link was sent
+	if access := ws.bot.checkEmailAccess(user.Email); access != emailApproved {
+		LogWarn("Web verification refused for %s: %s is blocked, no longer approved or couldn't be checked", user.DiscordUsername, user.Email)
+		refuseEmail(w, access)
+		return
+	}
+
+	if user.Verified || user.ReverifyLapsed {
+		ws.confirmReverification(w, user)
+		return
+	}
+
+	var teams []string
 
 	// Handle team selection if enabled
-	if ws.config.Features.EnableTeamSelection {
-		LogInfo("Processing web verification for %s (team: %s)", user.DiscordUsername, req.Team)
-
-		// Check if team exists
-		var exists bool
-		roleID, exists = ws.config.Teams[req.Team]
-		if !exists {
-			LogWarn("Invalid team selected: %s (user: %s)", req.Team, user.DiscordUsername)
-			http.Error(w, "Invalid team selection", http.StatusBadRequest)
+	if ws.config().Features.EnableTeamSelection {
+		teams = req.Teams
+		if req.Team != "" && !slices.Contains(teams, req.Team) {
+			teams = append(teams, req.Team)
+		}
+
+		// Domains with a locked team don't send one from the page
+		settings := ws.config().DomainSettingsFor(user.Email)
+		if settings.LockedTeam != "" {
+			teams = []string{settings.LockedTeam}
+		}
+		if len(teams) == 0 {
+			http.Error(w, "Team is required", http.StatusBadRequest)
 			return
 		}
-		teamName = req.Team
 
-		// Update database with team
-		if err := ws.db.UpdateUserTeam(user.DiscordID, req.Team); err != nil {
+		LogInfo("Processing web verification for %s (teams: %s)", user.DiscordUsername, strings.Join(teams, ", "))
+
+		// Check each team exists and is offered to this user's domain
+		choices := settings.TeamChoices(ws.config().Teams)
+		for _, team := range teams {
+			if _, exists := ws.config().Teams[team]; !exists || !slices.Contains(choices, team) {
+				LogWarn("Invalid team selected: %s (user: %s)", team, user.DiscordUsername)
+				http.Error(w, "Invalid team selection", http.StatusBadRequest)
+				return
+			}
+		}
+		sort.Strings(teams)
+		teams = slices.Compact(teams)
+	} else {
+		LogInfo("Processing web verification for %s (no team selection)", user.DiscordUsername)
+	}
+
+	// Roles wait for a moderator to click Approve
+	if ws.config().Features.RequireApproval {
+		ws.holdForApproval(w, user, teams)
+		return
+	}
+
+	if ws.config().Features.EnableTeamSelection {
+		// Update database with teams
+		if err := ws.db.VerifyUserWithTeams(user.DiscordID, teams); err != nil {
 			LogError("Error updating user team for %s: %v", user.DiscordUsername, err)
 			http.Error(w, "Failed to verify user", http.StatusInternalServerError)
 			return
 		}
 	} else {
-		LogInfo("Processing web verification for %s (no team selection)", user.DiscordUsername)
-
 		// Mark user as verified without team
 		if err := ws.db.MarkUserVerified(user.DiscordID); err != nil {
 			LogError("Error marking user verified for %s: %v", user.DiscordUsername, err)
@@ -148,37 +222,22 @@ func (ws *WebServer) handleAPIVerify(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Assign base members role (if configured)
-	if ws.config.Discord.MembersRole != "" {
-		if err := ws.bot.AssignRole(user.DiscordID, ws.config.Discord.MembersRole); err != nil {
-			LogError("Error assigning members role to %s: %v", user.DiscordUsername, err)
-			// Don't fail the request, but log the error
-		} else {
-			LogDebug("Assigned members role to %s", user.DiscordUsername)
-		}
-	}
-
-	// Assign team role in Discord if team selection is enabled
-	if ws.config.Features.EnableTeamSelection && roleID != "" {
-		if err := ws.bot.AssignRole(user.DiscordID, roleID); err != nil {
-			LogError("Error assigning team role to %s: %v", user.DiscordUsername, err)
-			// Don't fail the request, but log the error
-		} else {
-			LogDebug("Assigned %s team role to %s", teamName, user.DiscordUsername)
-		}
-	}
+	// Assign roles in Discord; failures are logged but don't fail the request
+	ws.bot.grantVerifiedRoles(user, teams)
 
 	// Send success DM
-	var successDM string
-	if ws.config.Features.EnableTeamSelection {
-		successDM = fmt.Sprintf("✅ Verification complete! Welcome to the %s team. You now have access to the server.", teamName)
-	} else {
-		successDM = "✅ Verification complete! You now have access to the server."
-	}
-	ws.bot.SendDM(user.DiscordID, successDM)
+	ws.bot.SendDM(user.DiscordID, ws.bot.verificationCompleteDM(teams))
+
+	ws.events.Publish(Event{
+		Type:     EventVerification,
+		UserID:   user.DiscordID,
+		Username: user.DiscordUsername,
+		Email:    user.Email,
+		Team:     strings.Join(teams, ", "),
+	})
 
-	if ws.config.Features.EnableTeamSelection {
-		LogSuccess("User %s verified successfully (team: %s, email: %s)", user.DiscordUsername, teamName, user.Email)
+	if ws.config().Features.EnableTeamSelection {
+		LogSuccess("User %s verified successfully (teams: %s, email: %s)", user.DiscordUsername, strings.Join(teams, ", "), user.Email)
 	} else {
 		LogSuccess("User %s verified successfully (email: %s)", user.DiscordUsername, user.Email)
 	}
@@ -190,6 +249,24 @@ func (ws *WebServer) handleAPIVerify(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// holdForApproval puts a user who has just verified their email in the moderator approval queue
+func (ws *WebServer) holdForApproval(w http.ResponseWriter, user *User, teams []string) {
+	if err := ws.bot.requestVerificationApproval(user, teams); err != nil {
+		LogError("Error requesting approval for %s: %v", user.DiscordUsername, err)
+		http.Error(w, "Failed to submit your verification for approval. Please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	ws.bot.SendDM(user.DiscordID, "🛂 Your email is verified! A moderator needs to approve your account before you get access. I'll message you once they've decided.")
+	LogSuccess("User %s verified their email and is awaiting approval (email: %s)", user.DiscordUsername, user.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "awaiting_approval",
+		"message": "✅ Email verified! A moderator will review your request, and Heimdall will message you on Discord once you have access.",
+	})
+}
+
 func (ws *WebServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
@@ -299,18 +376,22 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
             color: #333;
             font-weight: 600;
         }
-        select {
-            width: 100%;
-            padding: 12px;
+        .team-list {
             border: 2px solid #e0e0e0;
             border-radius: 8px;
-            font-size: 16px;
+            padding: 8px 12px;
             margin-bottom: 20px;
-            transition: border-color 0.3s;
+            max-height: 240px;
+            overflow-y: auto;
         }
-        select:focus {
-            outline: none;
-            border-color: #667eea;
+        .team-option {
+            display: flex;
+            align-items: center;
+            gap: 10px;
+            margin: 0;
+            padding: 8px 0;
+            font-weight: 400;
+            cursor: pointer;
         }
         button {
             width: 100%;
@@ -359,7 +440,11 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
     <div class="container">
         <div class="logo">🛡️</div>
         <h1>Heimdall Verification</h1>
+        {{if .Reverify}}
+        <p class="subtitle">Confirm you still use this email to keep your access to the server</p>
+        {{else}}
         <p class="subtitle">Complete your verification to access the server</p>
+        {{end}}
         
         <div class="user-info">
             <p><strong>Discord:</strong> {{.DiscordUsername}}</p>
@@ -367,17 +452,21 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
         </div>
 
         <form id="verifyForm">
-            {{if .EnableTeamSelection}}
-            <label for="team">Select Your Team:</label>
-            <select id="team" name="team" required>
-                <option value="">-- Choose a team --</option>
-                {{range $team, $roleID := .Teams}}
-                <option value="{{$team}}">{{$team}}</option>
+            {{if .Reverify}}
+            {{else if .LockedTeam}}
+            <div class="user-info">
+                <p><strong>Team:</strong> {{.LockedTeam}}</p>
+            </div>
+            {{else if .EnableTeamSelection}}
+            <label>Select Your Teams:</label>
+            <div class="team-list" id="teams">
+                {{range .Teams}}
+                <label class="team-option"><input type="checkbox" name="team" value="{{.}}"{{if eq . $.DefaultTeam}} checked{{end}}> {{.}}</label>
                 {{end}}
-            </select>
+            </div>
             {{end}}
 
-            <button type="submit" id="submitBtn">Complete Verification</button>
+            <button type="submit" id="submitBtn">{{if .Reverify}}Confirm Email{{else}}Complete Verification{{end}}</button>
         </form>
 
         <div class="success-message" id="successMsg">
@@ -390,14 +479,14 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
         document.getElementById('verifyForm').addEventListener('submit', async (e) => {
             e.preventDefault();
 
-            const teamElement = document.getElementById('team');
-            const team = teamElement ? teamElement.value : '';
+            const teamList = document.getElementById('teams');
+            const teams = Array.from(document.querySelectorAll('input[name="team"]:checked')).map(box => box.value);
             const submitBtn = document.getElementById('submitBtn');
             const successMsg = document.getElementById('successMsg');
             const errorMsg = document.getElementById('errorMsg');
 
-            if (teamElement && !team) {
-                errorMsg.textContent = 'Please select a team';
+            if (teamList && teams.length === 0) {
+                errorMsg.textContent = 'Please select at least one team';
                 errorMsg.style.display = 'block';
                 return;
             }
@@ -411,8 +500,8 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
                 const code = urlParams.get('code');
 
                 const body = { code };
-                if (team) {
-                    body.team = team;
+                if (teams.length > 0) {
+                    body.teams = teams;
                 }
 
                 const response = await fetch('/api/verify', {
@@ -428,13 +517,17 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
                     throw new Error(data || 'Verification failed');
                 }
 
+                const data = await response.json().catch(() => ({}));
+                if ((data.status === 'awaiting_approval' || data.status === 'reverified') && data.message) {
+                    successMsg.textContent = data.message;
+                }
                 successMsg.style.display = 'block';
                 document.getElementById('verifyForm').style.display = 'none';
             } catch (error) {
                 errorMsg.textContent = error.message;
                 errorMsg.style.display = 'block';
                 submitBtn.disabled = false;
-                submitBtn.textContent = 'Complete Verification';
+                submitBtn.textContent = {{if .Reverify}}'Confirm Email'{{else}}'Complete Verification'{{end}};
             }
         });
     </script>
@@ -447,16 +540,26 @@ func (ws *WebServer) renderVerificationPage(w http.ResponseWriter, user *User) {
 		return
 	}
 
+	settings := ws.config().DomainSettingsFor(user.Email)
+
 	data := struct {
 		DiscordUsername     string
 		Email               string
-		Teams               map[string]string
+		Teams               []string
+		DefaultTeam         string
+		LockedTeam          string
 		EnableTeamSelection bool
+		Reverify            bool
 	}{
 		DiscordUsername:     user.DiscordUsername,
 		Email:               user.Email,
-		Teams:               ws.config.Teams,
-		EnableTeamSelection: ws.config.Features.EnableTeamSelection,
+		Teams:               settings.TeamChoices(ws.config().Teams),
+		DefaultTeam:         settings.DefaultTeam,
+		EnableTeamSelection: ws.config().Features.EnableTeamSelection,
+		Reverify:            user.Verified || user.ReverifyLapsed,
+	}
+	if data.EnableTeamSelection {
+		data.LockedTeam = settings.LockedTeam
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -508,7 +611,7 @@ func (ws *WebServer) renderAlreadyVerified(w http.ResponseWriter, user *User) {
         }
         .team-badge {
             display: inline-block;
-            margin-top: 20px;
+            margin: 20px 4px 0;
             padding: 10px 20px;
             background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
             color: white;
@@ -519,10 +622,17 @@ func (ws *WebServer) renderAlreadyVerified(w http.ResponseWriter, user *User) {
 </head>
 <body>
     <div class="container">
+        {{if .AwaitingApproval}}
+        <div class="logo">🛂</div>
+        <h1>Awaiting Approval</h1>
+        <p>Your email has been verified. A moderator needs to approve your account, and Heimdall will message you on Discord once they have.</p>
+        {{else}}
         <div class="logo">✅</div>
         <h1>Already Verified</h1>
         <p>Your account has already been verified.</p>
-        <div class="team-badge">{{.TeamRole}}</div>
+        {{end}}
+        {{range .Teams}}<div class="team-badge">{{.}}</div>
+        {{end}}
         <p style="margin-top: 20px;">You can close this page and return to Discord.</p>
     </div>
 </body>
@@ -535,9 +645,11 @@ func (ws *WebServer) renderAlreadyVerified(w http.ResponseWriter, user *User) {
 	}
 
 	data := struct {
-		TeamRole string
+		Teams            []string
+		AwaitingApproval bool
 	}{
-		TeamRole: user.TeamRole,
+		Teams:            user.Teams,
+		AwaitingApproval: user.AwaitingApproval,
 	}
 
 	w.Header().Set("Content-Type", "text/html")